@@ -2,10 +2,12 @@ package access
 
 import (
 	"os"
+	"math"
 	"strings"
 	"path/filepath"
 	"strconv"
 	"errors"
+	"net"
 	"regexp"
 	"sargon/diag"
 	"sargon/wildmat"
@@ -17,12 +19,72 @@ type ACE struct {
 	Host []string
 	Allow []string
 	Deny []string
+	Role []string
+	OwnerOnly []string
 	Mount []string
 	AllowPrivileged *bool
+	AllowPrivilegedExec *bool
+	ExecUser []string
+	ContainerUser []string
+	ExecEnv []string
+	ExecCommand []string
+	CopyFrom []string
+	CopyTo []string
+	AllowHostNetwork *bool
+	AllowHostPid *bool
+	AllowHostIpc *bool
+	AllowHostUts *bool
+	AllowHostUserns *bool
+	AllowHostCgroupns *bool
 	MaxMemory *int64
 	MaxKernelMemory *int64
+	MaxMemorySwap *int64
+	MaxMemoryReservation *int64
+	MaxShmSize *int64
+	MaxNanoCpus *int64
+	MaxCpuShares *int64
+	MaxPidsLimit *int64
+	CpusetCpus []string
+	CpusetMems []string
+	MaxUlimit []string
+	RequireLimits *bool
+	RestartPolicy []string
 	AllowCapability []string
+	Device []string
+	DeviceCgroupRule []string
+	DeviceRequest []string
+	SecurityOpt []string
+	SeccompProfile []string
+	RequireNoNewPrivileges *bool
+	HostPort []string
+	HostIp []string
+	AllowPrivilegedPorts *bool
+	ResourceName []string
+	AllowAnonymousName *bool
+	RequireLabel []string
+	VolumeDriver []string
+	ForbidLabel []string
+	ExtraHost []string
+	Sysctl []string
+	BuildRemote []string
+	BuildPlatform []string
+	Network []string
+	NetworkDriver []string
+	NetworkParent []string
+	NetworkSubnet []string
+	AllowInternalNetwork *bool
+	AllowAttachableNetwork *bool
+	AllowIngressNetwork *bool
+	Plugin []string
+	Secret []string
+	Config []string
+	Image []string
+	Registry []string
+	Repository []string
 	Order int
+	// Marks the Allow entries that come from roles (see expandActions
+	// and mergeACE)
+	roleAllow []bool
 }
 
 type ACL []ACE
@@ -70,28 +132,78 @@ func (ace ACE) MatchUser(username string) bool {
 	return false
 }
 
-func (ace ACE) ActionIsAllowed(action string) (result EvalResult) {
-	for _, act := range ace.Allow {
-		if act == action {
-			return accept
+// Match action against a list of action names.  Each element is a
+// wildmat pattern, the word ALL, which matches any action, or a
+// category name prefixed with "@", which matches any action from
+// that category.  A pattern prefixed with an exclamation mark rejects
+// the actions it matches.  The first matching element decides.
+// Returns undef if none matches.
+func matchActionList(list []string, action string, categories []string) EvalResult {
+	for _, act := range list {
+		res := EvalResult(accept)
+		if strings.HasPrefix(act, "!") {
+			res = reject
+			act = act[1:]
 		}
-		if act == "ALL" {
-			result = accept
-			break
+		if strings.HasPrefix(act, "@") {
+			for _, cat := range categories {
+				if act[1:] == cat {
+					return res
+				}
+			}
+		} else if act == "ALL" || wildmat.Match(act, action, wildmat.GlobLex) {
+			return res
 		}
 	}
-	for _, act := range ace.Deny {
-		if act == action || act == "ALL" {
-			result = reject
-			break
+	return undef
+}
+
+// Check if the action is allowed.  Categories lists the names of the
+// categories the action belongs to.  An action explicitly named in
+// Allow is accepted, unless the name comes from a role, either merged
+// into the entry or referred to in Allow.  Otherwise, the action is rejected if it is matched by Deny,
+// and accepted if it is matched by Allow.  Negated patterns exclude
+// actions from the corresponding list.
+func (ace ACE) ActionIsAllowed(action string, categories []string) (result EvalResult) {
+	for i, act := range ace.Allow {
+		if act == action && !ace.fromRole(i) {
+			return accept
 		}
 	}
+	if matchActionList(ace.Allow, action, categories).Accept() {
+		result = accept
+	}
+	if matchActionList(ace.Deny, action, categories).Accept() {
+		result = reject
+	}
 	return
 }
 
-func (acl ACL) ActionIsAllowed(action string) (bool, string) {
+// Check if the i-th Allow entry comes from a role.
+func (ace ACE) fromRole(i int) bool {
+	return i < len(ace.roleAllow) && ace.roleAllow[i]
+}
+
+func (acl ACL) ActionIsAllowed(action string, categories []string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.ActionIsAllowed(action, categories)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return false, "default policy"
+}
+
+// Check if the action may be performed only on the resources owned by
+// the user.  OwnerOnly lists such actions (see matchActionList).  An
+// action prefixed with an exclamation mark is explicitly exempted.
+func (ace ACE) OwnerOnlyAction(action string, categories []string) EvalResult {
+	return matchActionList(ace.OwnerOnly, action, categories)
+}
+
+func (acl ACL) OwnerOnlyAction(action string, categories []string) (bool, string) {
 	for _, ace := range acl {
-		res := ace.ActionIsAllowed(action)
+		res := ace.OwnerOnlyAction(action, categories)
 		if res.Defined() {
 			return res.Accept(), ace.Id
 		}
@@ -119,6 +231,178 @@ func (acl ACL) CreatePrivilegedIsAllowed() (bool, string) {
 	return false, "default policy"
 }
 
+// Check if privileged exec is allowed.  If no entry has
+// AllowPrivilegedExec, the decision is made by AllowPrivileged.
+func (acl ACL) ExecPrivilegedIsAllowed() (bool, string) {
+	for _, ace := range acl {
+		if ace.AllowPrivilegedExec != nil {
+			return *ace.AllowPrivilegedExec, ace.Id
+		}
+	}
+	return acl.CreatePrivilegedIsAllowed()
+}
+
+func (ace ACE) ExecUserIsAllowed(user string) EvalResult {
+	return matchPatternList(ace.ExecUser, wildmat.GlobLex, user)
+}
+
+func (acl ACL) ExecUserIsAllowed(user string) (bool, string) {
+	user = NormalizeUser(user)
+	for _, ace := range acl {
+		res := ace.ExecUserIsAllowed(user)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Normalize user specification USER[:GROUP]: convert numeric IDs to
+// canonical form, so that e.g. "00" or "+0" is matched as "0".  IDs
+// are parsed the same way as docker does it.
+func NormalizeUser(user string) string {
+	a := strings.SplitN(user, ":", 2)
+	for i, s := range a {
+		if n, err := strconv.Atoi(s); err == nil {
+			a[i] = strconv.Itoa(n)
+		}
+	}
+	return strings.Join(a, ":")
+}
+
+func (ace ACE) ContainerUserIsAllowed(user string) EvalResult {
+	return matchPatternList(ace.ContainerUser, wildmat.GlobLex, user)
+}
+
+// Check if the container process is allowed to run as user.
+func (acl ACL) ContainerUserIsAllowed(user string) (bool, string) {
+	user = NormalizeUser(user)
+	for _, ace := range acl {
+		res := ace.ContainerUserIsAllowed(user)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) ExecEnvIsAllowed(env string) EvalResult {
+	return matchPatternList(ace.ExecEnv, wildmat.GlobLex, env)
+}
+
+func (acl ACL) ExecEnvIsAllowed(env string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.ExecEnvIsAllowed(env)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) ExecCommandIsAllowed(cmd string) EvalResult {
+	return matchPatternList(ace.ExecCommand, wildmat.GlobLex, cmd)
+}
+
+func (acl ACL) ExecCommandIsAllowed(cmd string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.ExecCommandIsAllowed(cmd)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) CopyFromIsAllowed(path string) EvalResult {
+	return matchPatternList(ace.CopyFrom, wildmat.GlobLex, path)
+}
+
+// Check if files can be copied from the given path in the container.
+func (acl ACL) CopyFromIsAllowed(path string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.CopyFromIsAllowed(path)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Check if the whole file system of a container can be exported.
+// Export bypasses the path restrictions, so it is denied if any entry
+// restricts copying files from containers.
+func (acl ACL) ExportIsAllowed() (bool, string) {
+	for _, ace := range acl {
+		if len(ace.CopyFrom) > 0 {
+			return false, ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) CopyToIsAllowed(path string) EvalResult {
+	return matchPatternList(ace.CopyTo, wildmat.GlobLex, path)
+}
+
+// Check if files can be copied to the given path in the container.
+func (acl ACL) CopyToIsAllowed(path string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.CopyToIsAllowed(path)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Host namespaces
+const (
+	NamespaceNetwork = "network"
+	NamespacePid = "pid"
+	NamespaceIpc = "ipc"
+	NamespaceUts = "uts"
+	NamespaceUserns = "userns"
+	NamespaceCgroupns = "cgroupns"
+)
+
+func (ace ACE) HostNamespaceIsAllowed(ns string) EvalResult {
+	var allow *bool
+	switch ns {
+	case NamespaceNetwork:
+		allow = ace.AllowHostNetwork
+	case NamespacePid:
+		allow = ace.AllowHostPid
+	case NamespaceIpc:
+		allow = ace.AllowHostIpc
+	case NamespaceUts:
+		allow = ace.AllowHostUts
+	case NamespaceUserns:
+		allow = ace.AllowHostUserns
+	case NamespaceCgroupns:
+		allow = ace.AllowHostCgroupns
+	}
+	if allow == nil {
+		return undef
+	}
+	if *allow {
+		return accept
+	}
+	return reject
+}
+
+// Check if the container is allowed to share the given namespace with
+// the host.
+func (acl ACL) HostNamespaceIsAllowed(ns string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.HostNamespaceIsAllowed(ns)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return false, "default policy"
+}
+
 func NormalizeCap(cap string) string {
 	cap = strings.ToUpper(cap)
 	if !strings.HasPrefix(cap, "CAP_") {
@@ -215,8 +499,14 @@ func (ace ACE) MountIsAllowed(dir string, ro bool) EvalResult {
 	return undef
 }
 
+// Check if the source of a bind (HOST-SRC in -v HOST-SRC:CONTAINER-DEST)
+// is a volume name rather than a host directory.
+func IsVolumeName(src string) bool {
+	return volumeRe.FindStringIndex(src) != nil
+}
+
 func (acl ACL) MountIsAllowed(dir string, ro bool) (bool, string) {
-	if volumeRe.FindStringIndex(dir) != nil {
+	if IsVolumeName(dir) {
 		// Volume mounts are allowed
 		return true, "volume mount"
 	}
@@ -238,65 +528,1009 @@ func (acl ACL) MountIsAllowed(dir string, ro bool) (bool, string) {
 	return false, "default policy"
 }
 
-func ConvSize(str string) (int64, error) {
-	factor := 1
-	if strings.HasSuffix(str, "k") || strings.HasSuffix(str, "K") {
-		factor = 1024
-		str = str[0:len(str)-1]
-	} else if strings.HasSuffix(str, "m") || strings.HasSuffix(str, "M") {
-		factor = 1024 * 1024;
-		str = str[0:len(str)-1]
-	} else if strings.HasSuffix(str, "g") || strings.HasSuffix(str, "G") {
-		factor = 1024 * 1024 * 1024;
-		str = str[0:len(str)-1]
+func (ace ACE) VolumeDriverIsAllowed(driver string) EvalResult {
+	return matchPatternList(ace.VolumeDriver, wildmat.GlobLex, driver)
+}
+
+// Check if the volume driver may be used.
+func (acl ACL) VolumeDriverIsAllowed(driver string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.VolumeDriverIsAllowed(driver)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
 	}
-	n, err := strconv.ParseInt(str, 10, 64)
-	if err != nil {
-		return n, err
+	return true, "default policy"
+}
+
+func (ace ACE) RestartPolicyIsAllowed(policy string) EvalResult {
+	return matchPatternList(ace.RestartPolicy, wildmat.GlobLex, policy)
+}
+
+// Check if the container restart policy is allowed.
+func (acl ACL) RestartPolicyIsAllowed(policy string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.RestartPolicyIsAllowed(policy)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
 	}
-	if n < 0 {
-		return -1, errors.New("value out of range")
+	return true, "default policy"
+}
 
+// Normalize extra host entry HOST:IP.  Newer docker versions accept
+// HOST=IP as well.
+func NormalizeExtraHost(host string) string {
+	if i := strings.Index(host, "="); i != -1 {
+		return host[0:i] + ":" + host[i+1:]
 	}
-	return n * int64(factor), nil
+	return host
 }
 
-func (ace ACE) CheckMaxMemory(lim int64) EvalResult {
-	if ace.MaxMemory == nil {
+func (ace ACE) ExtraHostIsAllowed(host string) EvalResult {
+	return matchPatternList(ace.ExtraHost, wildmat.GlobLex, host)
+}
+
+// Check if the extra host entry HOST:IP is allowed.
+func (acl ACL) ExtraHostIsAllowed(host string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.ExtraHostIsAllowed(host)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) SysctlIsAllowed(sysctl string) EvalResult {
+	return matchPatternList(ace.Sysctl, wildmat.GlobLex, sysctl)
+}
+
+// Check if setting the kernel parameter, given as NAME=VALUE, is allowed.
+func (acl ACL) SysctlIsAllowed(sysctl string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.SysctlIsAllowed(sysctl)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) BuildRemoteIsAllowed(remote string) EvalResult {
+	return matchPatternList(ace.BuildRemote, wildmat.GlobLex, remote)
+}
+
+// Check if the image can be built from the remote context (URL).
+func (acl ACL) BuildRemoteIsAllowed(remote string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.BuildRemoteIsAllowed(remote)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) BuildPlatformIsAllowed(platform string) EvalResult {
+	return matchPatternList(ace.BuildPlatform, wildmat.GlobLex, platform)
+}
+
+// Check if the image can be built for the platform.
+func (acl ACL) BuildPlatformIsAllowed(platform string) (bool, string) {
+	platform = strings.ToLower(platform)
+	for _, ace := range acl {
+		res := ace.BuildPlatformIsAllowed(platform)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Check if mounting a remote file system is allowed.  The uri is
+// formed as TYPE://SERVER/PATH, e.g. nfs://fileserver/export/home.
+func (acl ACL) RemoteMountIsAllowed(uri string, ro bool) (bool, string) {
+	for _, ace := range acl {
+		res := ace.MountIsAllowed(uri, ro)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return false, "default policy"
+}
+
+// Match names against a list of wildmat patterns.  A pattern prefixed
+// with an exclamation mark rejects the name it matches.  The first
+// matching pattern decides.  If the list is not empty and none of its
+// patterns matches, the name is rejected.
+func matchPatternList(patterns []string, glob int, names ...string) EvalResult {
+	if len(patterns) == 0 {
 		return undef
 	}
-	if *ace.MaxMemory < lim {
-		return reject
+	for _, pat := range patterns {
+		res := EvalResult(accept)
+		if strings.HasPrefix(pat, "!") {
+			res = reject
+			pat = pat[1:]
+		}
+		for _, name := range names {
+			if name != "" && wildmat.Match(pat, name, glob) {
+				return res
+			}
+		}
 	}
-	return accept
+	return reject
 }
 
-func (acl ACL) CheckMaxMemory(kw string, size int64) (bool, int64, string) {
+// Normalize security option: use equals sign as the key/value separator
+// (the colon is accepted by docker for backward compatibility).
+func NormalizeSecurityOpt(opt string) string {
+	if !strings.Contains(opt, "=") {
+		opt = strings.Replace(opt, ":", "=", 1)
+	}
+	return opt
+}
+
+func (ace ACE) SecurityOptIsAllowed(opt string) EvalResult {
+	return matchPatternList(ace.SecurityOpt, wildmat.GlobLex, opt)
+}
+
+func (acl ACL) SecurityOptIsAllowed(opt string) (bool, string) {
+	opt = NormalizeSecurityOpt(opt)
 	for _, ace := range acl {
-		if res := ace.CheckMaxMemory(size); res.Defined() {
-			return res.Accept(), *ace.MaxMemory, ace.Id
+		res := ace.SecurityOptIsAllowed(opt)
+		if res.Defined() {
+			return res.Accept(), ace.Id
 		}
 	}
-	return true, 0, "default policy"
+	return true, "default policy"
 }
 
-func (ace ACE) CheckMaxKernelMemory(lim int64) EvalResult {
-	if ace.MaxKernelMemory == nil {
+func (ace ACE) SeccompProfileIsAllowed(digest string) EvalResult {
+	if len(ace.SeccompProfile) == 0 {
 		return undef
 	}
-	if *ace.MaxKernelMemory < lim {
-		return reject
+	for _, d := range ace.SeccompProfile {
+		if strings.ToLower(strings.TrimPrefix(d, "sha256:")) == digest {
+			return accept
+		}
 	}
-	return accept
+	return reject
 }
 
-func (acl ACL) CheckMaxKernelMemory(kw string, size int64) (bool, int64, string) {
+// Check if custom seccomp profile with the given SHA-256 digest (in
+// hex) is allowed.
+func (acl ACL) SeccompProfileIsAllowed(digest string) (bool, string) {
 	for _, ace := range acl {
-		if res := ace.CheckMaxKernelMemory(size); res.Defined() {
-			return res.Accept(), *ace.MaxKernelMemory, ace.Id
+		res := ace.SeccompProfileIsAllowed(digest)
+		if res.Defined() {
+			return res.Accept(), ace.Id
 		}
 	}
-	return true, 0, "default policy"
+	return true, "default policy"
+}
+
+func (acl ACL) NoNewPrivilegesRequired() (bool, string) {
+	for _, ace := range acl {
+		if ace.RequireNoNewPrivileges != nil {
+			return *ace.RequireNoNewPrivileges, ace.Id
+		}
+	}
+	return false, "default policy"
+}
+
+// Parse port range specification N-M or N.
+func ParsePortRange(spec string) (lo, hi int, err error) {
+	a := strings.SplitN(spec, "-", 2)
+	if lo, err = strconv.Atoi(strings.TrimSpace(a[0])); err != nil {
+		return
+	}
+	hi = lo
+	if len(a) == 2 {
+		if hi, err = strconv.Atoi(strings.TrimSpace(a[1])); err != nil {
+			return
+		}
+	}
+	if lo < 0 || hi > 65535 || lo > hi {
+		err = errors.New("invalid port range")
+	}
+	return
+}
+
+// Check if the host port range lo-hi is allowed.  Each element of
+// HostPort is a port range, optionally prefixed with an exclamation
+// mark to deny the ports in it.  The first range that includes lo-hi
+// (or, for denying ranges, overlaps with it) decides.
+func (ace ACE) HostPortIsAllowed(lo, hi int) EvalResult {
+	if len(ace.HostPort) == 0 {
+		return undef
+	}
+	for _, spec := range ace.HostPort {
+		deny := strings.HasPrefix(spec, "!")
+		if deny {
+			spec = spec[1:]
+		}
+		if spec == "" {
+			// Variable could not be expanded
+			continue
+		}
+		plo, phi, err := ParsePortRange(spec)
+		if err != nil {
+			diag.Error("%s: bad port range %s: %s\n", ace.Id, spec, err.Error())
+			continue
+		}
+		if deny {
+			if lo <= phi && plo <= hi {
+				return reject
+			}
+		} else if plo <= lo && hi <= phi {
+			return accept
+		}
+	}
+	return reject
+}
+
+func (acl ACL) HostPortIsAllowed(lo, hi int) (bool, string) {
+	for _, ace := range acl {
+		res := ace.HostPortIsAllowed(lo, hi)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) HostIpIsAllowed(ip string) EvalResult {
+	return matchPatternList(ace.HostIp, wildmat.GlobLex, ip)
+}
+
+// Check if publishing ports on the host address ip is allowed.  Empty
+// address stands for all interfaces.
+func (acl ACL) HostIpIsAllowed(ip string) (bool, string) {
+	if ip == "" {
+		ip = "0.0.0.0"
+	}
+	for _, ace := range acl {
+		res := ace.HostIpIsAllowed(ip)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (acl ACL) PrivilegedPortIsAllowed() (bool, string) {
+	for _, ace := range acl {
+		if ace.AllowPrivilegedPorts != nil {
+			return *ace.AllowPrivilegedPorts, ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) ResourceNameIsAllowed(name string) EvalResult {
+	return matchPatternList(ace.ResourceName, wildmat.GlobLex, name)
+}
+
+// Check if the container, volume or network can be given this name.
+func (acl ACL) ResourceNameIsAllowed(name string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.ResourceNameIsAllowed(name)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) AnonymousNameIsAllowed() EvalResult {
+	if ace.AllowAnonymousName == nil {
+		return undef
+	}
+	if *ace.AllowAnonymousName {
+		return accept
+	}
+	return reject
+}
+
+// Check if containers and volumes can be created without a name.
+func (acl ACL) AnonymousNameIsAllowed() (bool, string) {
+	for _, ace := range acl {
+		res := ace.AnonymousNameIsAllowed()
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Return required labels in the form KEY or KEY=PATTERN, and the id of
+// the ACE that requires them.  The first ACE that has RequireLabel
+// decides.
+func (acl ACL) RequiredLabels() ([]string, string) {
+	for _, ace := range acl {
+		if len(ace.RequireLabel) > 0 {
+			return ace.RequireLabel, ace.Id
+		}
+	}
+	return nil, "default policy"
+}
+
+// Check if label with the given key is required by the requirement
+// req and, if so, whether its value satisfies it.
+func LabelSatisfies(req string, key, value string) (match, ok bool) {
+	a := strings.SplitN(req, "=", 2)
+	if a[0] != key {
+		return false, false
+	}
+	if len(a) == 1 {
+		return true, true
+	}
+	if a[1] == "" {
+		return true, value == ""
+	}
+	return true, wildmat.Match(a[1], value, wildmat.GlobLex)
+}
+
+func (ace ACE) LabelIsAllowed(key string) EvalResult {
+	if matchAnyPattern(ace.ForbidLabel, wildmat.GlobLex, key).Accept() {
+		return reject
+	}
+	return undef
+}
+
+// Check if setting the label is allowed, i.e. not forbidden by any
+// ForbidLabel pattern.
+func (acl ACL) LabelIsAllowed(key string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.LabelIsAllowed(key)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) ImageIsAllowed(names ...string) EvalResult {
+	return matchPatternList(ace.Image, wildmat.GlobLex, names...)
+}
+
+// Check if the image is allowed.  The image reference is matched in
+// its canonical form (see ImageRef.String), so that differently spelled
+// references to the same image are treated alike.  An image ID can
+// refer to any local image, so it is denied if any image policy
+// applies.
+func (acl ACL) ImageIsAllowed(image string) (bool, string) {
+	if IsImageId(image) {
+		for _, ace := range acl {
+			if len(ace.Image) > 0 {
+				return false, ace.Id
+			}
+		}
+		return true, "default policy"
+	}
+	canon := ParseImageRef(image).String()
+	for _, ace := range acl {
+		res := ace.ImageIsAllowed(canon)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) SecretIsAllowed(name string) EvalResult {
+	return matchPatternList(ace.Secret, wildmat.GlobLex, name)
+}
+
+// Check if the user can use the swarm secret.
+func (acl ACL) SecretIsAllowed(name string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.SecretIsAllowed(name)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Check if the user can use a swarm secret of unknown name.  This is
+// allowed only if no entry restricts secrets.
+func (acl ACL) UnknownSecretIsAllowed() (bool, string) {
+	for _, ace := range acl {
+		if len(ace.Secret) > 0 {
+			return false, ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) ConfigIsAllowed(name string) EvalResult {
+	return matchPatternList(ace.Config, wildmat.GlobLex, name)
+}
+
+// Check if the user can use the swarm config.
+func (acl ACL) ConfigIsAllowed(name string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.ConfigIsAllowed(name)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Check if the user can use a swarm config of unknown name.  This is
+// allowed only if no entry restricts configs.
+func (acl ACL) UnknownConfigIsAllowed() (bool, string) {
+	for _, ace := range acl {
+		if len(ace.Config) > 0 {
+			return false, ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) NetworkIsAllowed(name string) EvalResult {
+	return matchPatternList(ace.Network, wildmat.GlobLex, name)
+}
+
+// Check if containers can be attached to the network.
+func (acl ACL) NetworkIsAllowed(name string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.NetworkIsAllowed(name)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) NetworkDriverIsAllowed(driver string) EvalResult {
+	return matchPatternList(ace.NetworkDriver, wildmat.GlobLex, driver)
+}
+
+// Check if the network driver can be used.
+func (acl ACL) NetworkDriverIsAllowed(driver string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.NetworkDriverIsAllowed(driver)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) NetworkParentIsAllowed(iface string) EvalResult {
+	return matchPatternList(ace.NetworkParent, wildmat.GlobLex, iface)
+}
+
+// Check if the host interface can be used as the parent of a macvlan
+// or ipvlan network.
+func (acl ACL) NetworkParentIsAllowed(iface string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.NetworkParentIsAllowed(iface)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Check if the subnet b is contained in the subnet a.
+func subnetContains(a, b *net.IPNet) bool {
+	abits, alen := a.Mask.Size()
+	bbits, blen := b.Mask.Size()
+	return alen == blen && abits <= bbits && a.Contains(b.IP)
+}
+
+// Check if subnets a and b overlap.
+func subnetOverlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// Match subnet against a list of CIDRs.  A CIDR prefixed with an
+// exclamation mark rejects any subnet that overlaps with it, other
+// CIDRs accept subnets they contain.  The first matching CIDR decides.
+// If the list is not empty and none of its CIDRs matches, the subnet
+// is rejected.
+func matchSubnetList(list []string, subnet *net.IPNet) EvalResult {
+	if len(list) == 0 {
+		return undef
+	}
+	for _, s := range list {
+		neg := strings.HasPrefix(s, "!")
+		if neg {
+			s = s[1:]
+		}
+		_, cidr, err := net.ParseCIDR(s)
+		if err != nil {
+			diag.Error("invalid subnet %s: %s\n", s, err.Error())
+			continue
+		}
+		if neg {
+			if subnetOverlaps(cidr, subnet) {
+				return reject
+			}
+		} else if subnetContains(cidr, subnet) {
+			return accept
+		}
+	}
+	return reject
+}
+
+// Check if the network can use the given IPAM subnet.
+func (acl ACL) NetworkSubnetIsAllowed(subnet string) (bool, string) {
+	_, cidr, err := net.ParseCIDR(subnet)
+	if err != nil {
+		diag.Error("can't parse subnet %s: %s\n", subnet, err.Error())
+		return false, "(bad subnet)"
+	}
+	for _, ace := range acl {
+		res := matchSubnetList(ace.NetworkSubnet, cidr)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Network flags
+const (
+	NetworkInternal = "internal"
+	NetworkAttachable = "attachable"
+	NetworkIngress = "ingress"
+)
+
+func (ace ACE) NetworkFlagIsAllowed(flag string) EvalResult {
+	var allow *bool
+	switch flag {
+	case NetworkInternal:
+		allow = ace.AllowInternalNetwork
+	case NetworkAttachable:
+		allow = ace.AllowAttachableNetwork
+	case NetworkIngress:
+		allow = ace.AllowIngressNetwork
+	}
+	if allow == nil {
+		return undef
+	}
+	if *allow {
+		return accept
+	}
+	return reject
+}
+
+// Check if the network can be created with the given flag set.  By
+// default, internal and attachable networks are allowed, whereas
+// ingress networks are not.
+func (acl ACL) NetworkFlagIsAllowed(flag string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.NetworkFlagIsAllowed(flag)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return flag != NetworkIngress, "default policy"
+}
+
+func (ace ACE) PluginIsAllowed(names ...string) EvalResult {
+	return matchPatternList(ace.Plugin, wildmat.GlobLex, names...)
+}
+
+// Check if the plugin can be installed.  As with images, the plugin
+// reference is matched in its canonical form.
+func (acl ACL) PluginIsAllowed(plugin string) (bool, string) {
+	canon := ParseImageRef(plugin).String()
+	for _, ace := range acl {
+		res := ace.PluginIsAllowed(canon)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) RegistryIsAllowed(registry string) EvalResult {
+	return matchPatternList(ace.Registry, wildmat.GlobLex, registry)
+}
+
+// Check if pulling from the registry of the given image is allowed.
+func (acl ACL) RegistryIsAllowed(image string) (bool, string) {
+	registry := ParseImageRef(image).Registry
+	for _, ace := range acl {
+		res := ace.RegistryIsAllowed(registry)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+func (ace ACE) RepositoryIsAllowed(names ...string) EvalResult {
+	return matchPatternList(ace.Repository, wildmat.GlobLex, names...)
+}
+
+// Check if the repository can be used as a target for push or tag
+// operations.  As with images, the repository name is matched as
+// supplied and in its fully qualified form (see ImageRef.Name).
+func (acl ACL) RepositoryIsAllowed(repo string) (bool, string) {
+	name := ParseImageRef(repo).Name()
+	for _, ace := range acl {
+		res := ace.RepositoryIsAllowed(repo, name)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Check if images can be loaded from an archive.  Loaded images get
+// the names stored in the archive, bypassing the image and repository
+// restrictions, so loading is denied if any entry has them.
+func (acl ACL) LoadIsAllowed() (bool, string) {
+	for _, ace := range acl {
+		if len(ace.Repository) > 0 || len(ace.Image) > 0 {
+			return false, ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Return accept if name matches any of the patterns, undef otherwise.
+func matchAnyPattern(patterns []string, glob int, name string) EvalResult {
+	for _, pat := range patterns {
+		if wildmat.Match(pat, name, glob) {
+			return accept
+		}
+	}
+	return undef
+}
+
+func (ace ACE) DeviceIsAllowed(dev string) EvalResult {
+	return matchAnyPattern(ace.Device, wildmat.GlobLex, dev)
+}
+
+// Check if the host device can be made available in the container.
+// As with mounts, symbolic links in the device name are resolved first.
+func (acl ACL) DeviceIsAllowed(dev string) (bool, string) {
+	dev = filepath.Clean(dev)
+	path, err := RealPath(dev)
+	if err != nil {
+		diag.Error("can't resolve path %s: %s\n", dev, err.Error())
+		return false, "(bad path)"
+	}
+	if path != dev {
+		diag.Trace("%s is a symlink to %s\n", dev, path)
+	}
+	for _, ace := range acl {
+		res := ace.DeviceIsAllowed(path)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return false, "default policy"
+}
+
+func (ace ACE) DeviceCgroupRuleIsAllowed(rule string) EvalResult {
+	return matchAnyPattern(ace.DeviceCgroupRule, wildmat.GlobLex, rule)
+}
+
+func (acl ACL) DeviceCgroupRuleIsAllowed(rule string) (bool, string) {
+	rule = strings.Join(strings.Fields(rule), " ")
+	for _, ace := range acl {
+		res := ace.DeviceCgroupRuleIsAllowed(rule)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return false, "default policy"
+}
+
+func (ace ACE) DeviceRequestIsAllowed(req string) EvalResult {
+	return matchAnyPattern(ace.DeviceRequest, wildmat.GlobLex, req)
+}
+
+// Check if device request is allowed.  The req argument is the name of
+// the device driver, optionally followed by a colon and the name of
+// the requested capability, e.g. "nvidia:gpu".
+func (acl ACL) DeviceRequestIsAllowed(req string) (bool, string) {
+	for _, ace := range acl {
+		res := ace.DeviceRequestIsAllowed(req)
+		if res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return false, "default policy"
+}
+
+func ConvSize(str string) (int64, error) {
+	factor := 1
+	if strings.HasSuffix(str, "k") || strings.HasSuffix(str, "K") {
+		factor = 1024
+		str = str[0:len(str)-1]
+	} else if strings.HasSuffix(str, "m") || strings.HasSuffix(str, "M") {
+		factor = 1024 * 1024;
+		str = str[0:len(str)-1]
+	} else if strings.HasSuffix(str, "g") || strings.HasSuffix(str, "G") {
+		factor = 1024 * 1024 * 1024;
+		str = str[0:len(str)-1]
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return n, err
+	}
+	if n < 0 {
+		return -1, errors.New("value out of range")
+
+	}
+	return n * int64(factor), nil
+}
+
+// Convert number of CPUs in units of 1e-9 CPUs.  Unlike with ConvSize,
+// the K, M and G suffixes stand for decimal multipliers (1e3, 1e6 and
+// 1e9), so that e.g. "1.5G" means 1.5 CPUs.  Fractional values are
+// allowed only with a suffix.
+func ConvCpus(str string) (int64, error) {
+	factor := 1.0
+	switch {
+	case strings.HasSuffix(str, "k") || strings.HasSuffix(str, "K"):
+		factor = 1e3
+	case strings.HasSuffix(str, "m") || strings.HasSuffix(str, "M"):
+		factor = 1e6
+	case strings.HasSuffix(str, "g") || strings.HasSuffix(str, "G"):
+		factor = 1e9
+	default:
+		n, err := strconv.ParseInt(str, 10, 64)
+		if err == nil && n < 0 {
+			return -1, errors.New("value out of range")
+		}
+		return n, err
+	}
+	f, err := strconv.ParseFloat(str[0:len(str)-1], 64)
+	if err != nil {
+		return 0, err
+	}
+	f *= factor
+	if f < 0 || f >= math.MaxInt64 {
+		return -1, errors.New("value out of range")
+	}
+	return int64(math.Round(f)), nil
+}
+
+// Return the resource limit named kw (the name of the corresponding ACE
+// field, e.g. "MaxMemory"), or nil if it is not set.
+func (ace ACE) Limit(kw string) *int64 {
+	switch kw {
+	case "MaxMemory":
+		return ace.MaxMemory
+	case "MaxKernelMemory":
+		return ace.MaxKernelMemory
+	case "MaxMemorySwap":
+		return ace.MaxMemorySwap
+	case "MaxMemoryReservation":
+		return ace.MaxMemoryReservation
+	case "MaxShmSize":
+		return ace.MaxShmSize
+	case "MaxNanoCpus":
+		return ace.MaxNanoCpus
+	case "MaxCpuShares":
+		return ace.MaxCpuShares
+	case "MaxPidsLimit":
+		return ace.MaxPidsLimit
+	}
+	return nil
+}
+
+func (ace ACE) CheckLimit(kw string, val int64) EvalResult {
+	lim := ace.Limit(kw)
+	if lim == nil {
+		return undef
+	}
+	if *lim < val {
+		return reject
+	}
+	return accept
+}
+
+// Check the requested value of the resource against the limit kw.
+// Return the result, the limit value and the id of the deciding ACE.
+func (acl ACL) CheckLimit(kw string, val int64) (bool, int64, string) {
+	for _, ace := range acl {
+		if res := ace.CheckLimit(kw, val); res.Defined() {
+			return res.Accept(), *ace.Limit(kw), ace.Id
+		}
+	}
+	return true, 0, "default policy"
+}
+
+// Return the limit kw set by the first ACE that has it, and the id of
+// that ACE.  Return nil if no ACE sets the limit.
+func (acl ACL) Limit(kw string) (*int64, string) {
+	for _, ace := range acl {
+		if lim := ace.Limit(kw); lim != nil {
+			return lim, ace.Id
+		}
+	}
+	return nil, "default policy"
+}
+
+func (ace ACE) limitsRequired() bool {
+	return ace.RequireLimits != nil && *ace.RequireLimits
+}
+
+// Check if the resource must be explicitly limited, i.e. if the first
+// ACE that sets the limit kw has RequireLimits set.
+func (acl ACL) LimitIsRequired(kw string) (bool, string) {
+	for _, ace := range acl {
+		if ace.Limit(kw) != nil {
+			return ace.limitsRequired(), ace.Id
+		}
+	}
+	return false, "default policy"
+}
+
+func (ace ACE) CheckMaxMemory(lim int64) EvalResult {
+	return ace.CheckLimit("MaxMemory", lim)
+}
+
+func (acl ACL) CheckMaxMemory(kw string, size int64) (bool, int64, string) {
+	return acl.CheckLimit("MaxMemory", size)
+}
+
+func (ace ACE) CheckMaxKernelMemory(lim int64) EvalResult {
+	return ace.CheckLimit("MaxKernelMemory", lim)
+}
+
+func (acl ACL) CheckMaxKernelMemory(kw string, size int64) (bool, int64, string) {
+	return acl.CheckLimit("MaxKernelMemory", size)
+}
+
+// Parse cpuset specification, e.g. "0-3,5", into a set of numbers.
+func ParseCpuset(spec string) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, s := range strings.Split(spec, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		lo, hi, err := ParsePortRange(s)
+		if err != nil {
+			return nil, errors.New("invalid cpuset " + spec)
+		}
+		for i := lo; i <= hi; i++ {
+			set[i] = true
+		}
+	}
+	return set, nil
+}
+
+func (ace ACE) cpuset(kw string) []string {
+	switch kw {
+	case "CpusetCpus":
+		return ace.CpusetCpus
+	case "CpusetMems":
+		return ace.CpusetMems
+	}
+	return nil
+}
+
+func (ace ACE) CpusetIsAllowed(kw string, set map[int]bool) EvalResult {
+	list := ace.cpuset(kw)
+	if len(list) == 0 {
+		return undef
+	}
+	allowed, err := ParseCpuset(strings.Join(list, ","))
+	if err != nil {
+		diag.Error("%s: %s\n", ace.Id, err.Error())
+		return reject
+	}
+	for n := range set {
+		if !allowed[n] {
+			return reject
+		}
+	}
+	return accept
+}
+
+// Check if the requested cpuset (kw is "CpusetCpus" or "CpusetMems") is
+// a subset of the allowed one.
+func (acl ACL) CpusetIsAllowed(kw string, spec string) (bool, string) {
+	set, err := ParseCpuset(spec)
+	if err != nil {
+		return false, "(bad cpuset)"
+	}
+	for _, ace := range acl {
+		if res := ace.CpusetIsAllowed(kw, set); res.Defined() {
+			return res.Accept(), ace.Id
+		}
+	}
+	return true, "default policy"
+}
+
+// Check if the cpuset kw must be explicitly given.
+func (acl ACL) CpusetIsRequired(kw string) (bool, string) {
+	for _, ace := range acl {
+		if len(ace.cpuset(kw)) > 0 {
+			return ace.limitsRequired(), ace.Id
+		}
+	}
+	return false, "default policy"
+}
+
+// Parse ulimit ceiling in the form NAME=SOFT[:HARD].  If HARD is
+// omitted, it is the same as SOFT.
+func ParseUlimit(str string) (name string, soft, hard int64, err error) {
+	a := strings.SplitN(str, "=", 2)
+	if len(a) != 2 {
+		err = errors.New("invalid ulimit " + str)
+		return
+	}
+	name = a[0]
+	v := strings.SplitN(a[1], ":", 2)
+	if soft, err = ConvSize(v[0]); err != nil {
+		return
+	}
+	hard = soft
+	if len(v) == 2 {
+		hard, err = ConvSize(v[1])
+	}
+	return
+}
+
+func (ace ACE) CheckUlimit(name string, soft, hard int64) (EvalResult, string) {
+	for _, u := range ace.MaxUlimit {
+		n, s, h, err := ParseUlimit(u)
+		if err != nil {
+			diag.Error("%s: %s\n", ace.Id, err.Error())
+			continue
+		}
+		if n == name {
+			if soft > s || hard > h {
+				return reject, u
+			}
+			return accept, u
+		}
+	}
+	return undef, ""
+}
+
+// Return names of the ulimits that must be explicitly set, along with
+// the ids of the ACEs that require them.
+func (acl ACL) RequiredUlimits() map[string]string {
+	seen := make(map[string]bool)
+	req := make(map[string]string)
+	for _, ace := range acl {
+		for _, u := range ace.MaxUlimit {
+			name, _, _, err := ParseUlimit(u)
+			if err != nil || seen[name] {
+				continue
+			}
+			seen[name] = true
+			if ace.limitsRequired() {
+				req[name] = ace.Id
+			}
+		}
+	}
+	return req
+}
+
+// Check ulimit against the MaxUlimit ceilings.  Negative values mean
+// unlimited.  Return the result, the ceiling and the id of the
+// deciding ACE.
+func (acl ACL) CheckUlimit(name string, soft, hard int64) (bool, string, string) {
+	if soft < 0 {
+		soft = math.MaxInt64
+	}
+	if hard < 0 {
+		hard = math.MaxInt64
+	}
+	for _, ace := range acl {
+		if res, lim := ace.CheckUlimit(name, soft, hard); res.Defined() {
+			return res.Accept(), lim, ace.Id
+		}
+	}
+	return true, "", "default policy"
 }
 
 func Resolution(b bool) string {
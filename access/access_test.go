@@ -0,0 +1,100 @@
+package access
+
+import (
+	"testing"
+)
+
+func TestMatchActionList(t *testing.T) {
+	for _, tc := range []struct {
+		list []string
+		action string
+		categories []string
+		want EvalResult
+	}{
+		{ nil, "ContainerCreate", nil, undef },
+		{ []string{"ContainerCreate"}, "ContainerCreate", nil, accept },
+		{ []string{"ContainerCreate"}, "ContainerStart", nil, undef },
+		{ []string{"Container*"}, "ContainerStart", nil, accept },
+		{ []string{"*List"}, "ImageList", nil, accept },
+		{ []string{"*List"}, "ImageDelete", nil, undef },
+		{ []string{"!ContainerExec", "Container*"}, "ContainerExec", nil, reject },
+		{ []string{"!ContainerExec", "Container*"}, "ContainerStart", nil, accept },
+		{ []string{"Container*", "!ContainerExec"}, "ContainerExec", nil, accept },
+		{ []string{"ALL"}, "ImageList", nil, accept },
+		{ []string{"!ALL"}, "ImageList", nil, reject },
+		{ []string{"@readonly"}, "ImageList", []string{"readonly"}, accept },
+		{ []string{"@readonly"}, "ImageDelete",
+		  []string{"lifecycle", "destructive"}, undef },
+		{ []string{"!@destructive", "ALL"}, "VolumeDelete",
+		  []string{"lifecycle", "destructive"}, reject },
+		{ []string{"!@destructive", "ALL"}, "VolumeCreate",
+		  []string{"lifecycle"}, accept },
+		{ []string{"@readonly"}, "ImageList", nil, undef },
+	} {
+		if got := matchActionList(tc.list, tc.action, tc.categories); got != tc.want {
+			t.Errorf("matchActionList(%q, %s, %q) = %d, want %d",
+				tc.list, tc.action, tc.categories, got, tc.want)
+		}
+	}
+}
+
+func TestActionIsAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		acl ACL
+		action string
+		categories []string
+		want bool
+		id string
+	}{
+		{ ACL{}, "ImageList", nil, false, "default policy" },
+		{ ACL{{Id: "a", Allow: []string{"ALL"}, Deny: []string{"ContainerExec"}}},
+		  "ContainerExec", []string{"exec"}, false, "a" },
+		{ ACL{{Id: "a", Allow: []string{"ALL"}, Deny: []string{"ContainerExec"}}},
+		  "ContainerStart", []string{"lifecycle"}, true, "a" },
+		// Exact action name in Allow takes precedence over Deny
+		{ ACL{{Id: "a", Allow: []string{"ContainerExec"}, Deny: []string{"@exec"}}},
+		  "ContainerExec", []string{"exec"}, true, "a" },
+		{ ACL{{Id: "a", Allow: []string{"Container*"}, Deny: []string{"@exec"}}},
+		  "ContainerExec", []string{"exec"}, false, "a" },
+		// Negated pattern excludes the action, so the next entry decides
+		{ ACL{{Id: "a", Allow: []string{"!ContainerExec", "ALL"}},
+		      {Id: "b", Allow: []string{"ContainerExec"}}},
+		  "ContainerExec", []string{"exec"}, true, "b" },
+		{ ACL{{Id: "a", Allow: []string{"!ContainerExec", "ALL"}}},
+		  "ContainerExec", []string{"exec"}, false, "default policy" },
+		{ ACL{{Id: "a", Deny: []string{"@destructive"}},
+		      {Id: "b", Allow: []string{"ALL"}}},
+		  "VolumeDelete", []string{"lifecycle", "destructive"}, false, "a" },
+		{ ACL{{Id: "a", Deny: []string{"@destructive"}},
+		      {Id: "b", Allow: []string{"ALL"}}},
+		  "VolumeCreate", []string{"lifecycle"}, true, "b" },
+	} {
+		got, id := tc.acl.ActionIsAllowed(tc.action, tc.categories)
+		if got != tc.want || id != tc.id {
+			t.Errorf("ActionIsAllowed(%s) on %+v = %v, %s, want %v, %s",
+				tc.action, tc.acl, got, id, tc.want, tc.id)
+		}
+	}
+}
+
+func TestImageIsAllowed(t *testing.T) {
+	acl := ACL{{Id: "a", Image: []string{"docker.io/library/*"}}}
+	for _, tc := range []struct {
+		image string
+		want bool
+	}{
+		{ "debian", true },
+		{ "debian:10", true },
+		{ "example.com/debian", false },
+		{ "3f57d9401f8d", false },
+		{ "sha256:3f57d9401f8d", false },
+		{ "sha256:3f57d9401f8de6b8c59a6a5b2fb25e9d8c8ec9a4e1a2bb9d0e2d4b3c8f0a1b2c", false },
+	} {
+		if got, _ := acl.ImageIsAllowed(tc.image); got != tc.want {
+			t.Errorf("ImageIsAllowed(%s) = %v, want %v", tc.image, got, tc.want)
+		}
+	}
+	if got, id := (ACL{}).ImageIsAllowed("3f57d9401f8d"); !got || id != "default policy" {
+		t.Errorf("ImageIsAllowed without policy = %v, %s", got, id)
+	}
+}
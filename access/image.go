@@ -0,0 +1,80 @@
+package access
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	DefaultRegistry = "docker.io"
+	officialRepoPrefix = "library/"
+)
+
+// Parsed docker image reference.
+type ImageRef struct {
+	Registry string
+	Repository string
+	Tag string
+	Digest string
+}
+
+// Image ID, possibly abbreviated, optionally with the algorithm prefix.
+var imageIdRe = regexp.MustCompile(`^(?:sha256:)?[0-9a-f]{12,64}$`)
+
+// Check if the reference is an image ID rather than a name.  Docker
+// looks up a bare hex string of 12 or more digits as an ID prefix if no
+// image has such name.
+func IsImageId(ref string) bool {
+	return imageIdRe.MatchString(ref)
+}
+
+// Split image reference into registry, repository, tag and digest.
+// Missing registry defaults to docker.io, and official images on
+// docker.io get the "library/" prefix, as docker itself does.  Tag
+// is left empty if not given.
+func ParseImageRef(ref string) (r ImageRef) {
+	if i := strings.Index(ref, "@"); i != -1 {
+		r.Digest = ref[i+1:]
+		ref = ref[0:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i != -1 && !strings.Contains(ref[i+1:], "/") {
+		r.Tag = ref[i+1:]
+		ref = ref[0:i]
+	}
+	if i := strings.Index(ref, "/"); i != -1 {
+		s := ref[0:i]
+		if strings.ContainsAny(s, ".:") || s == "localhost" {
+			r.Registry = s
+			ref = ref[i+1:]
+		}
+	}
+	if r.Registry == "" || r.Registry == "index.docker.io" {
+		r.Registry = DefaultRegistry
+	}
+	if r.Registry == DefaultRegistry && !strings.Contains(ref, "/") {
+		ref = officialRepoPrefix + ref
+	}
+	r.Repository = ref
+	return
+}
+
+// Return fully qualified repository name, i.e. registry and repository
+// path, without tag and digest.
+func (r ImageRef) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// Return canonical form of the image reference.  Missing tag is
+// replaced with "latest", unless digest is present.
+func (r ImageRef) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	} else if r.Digest == "" {
+		s += ":latest"
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
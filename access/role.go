@@ -0,0 +1,170 @@
+package access
+
+import (
+	"reflect"
+	"strings"
+	"sargon/diag"
+)
+
+// Role expansion.  A role is a named ACE that other ACEs can refer to.
+// A reference "@NAME" in Allow or Deny stands for the actions of the
+// role, and a role named in the Role list contributes all its
+// attributes to the referring ACE.
+type roleResolver struct {
+	roles map[string]ACE
+	categories []string
+	done map[string]*ACE
+	busy map[string]bool
+}
+
+// Return the role with its own references expanded, or nil if no such
+// role exists or it refers to itself.
+func (rr *roleResolver) resolve(name string) *ACE {
+	if role, ok := rr.done[name]; ok {
+		return role
+	}
+	src, ok := rr.roles[name]
+	if !ok {
+		return nil
+	}
+	if rr.busy[name] {
+		diag.Error("role %s refers to itself\n", name)
+		return nil
+	}
+	rr.busy[name] = true
+	role := src
+	rr.expand(&role)
+	delete(rr.busy, name)
+	rr.done[name] = &role
+	return &role
+}
+
+// Expand role references in the ACE.
+func (rr *roleResolver) expand(ace *ACE) {
+	ace.Allow, ace.roleAllow = rr.expandActions(ace.Allow)
+	ace.Deny, _ = rr.expandActions(ace.Deny)
+	for _, name := range ace.Role {
+		if role := rr.resolve(name); role != nil {
+			mergeACE(ace, role)
+		} else {
+			diag.Error("%s: can't resolve role %s\n", ace.Id, name)
+		}
+	}
+}
+
+// Replace each role reference in the action list with the actions of
+// that role.  Actions denied by the role are included as negated
+// patterns in front of them.  In a negated reference, "!@NAME", each
+// action allowed by the role is negated.  References that don't name
+// a role are left intact: they refer to built-in action categories.
+// The second return value marks the actions that come from roles.  It
+// may be shorter than the resulting list: the actions past its end
+// don't come from roles.
+func (rr *roleResolver) expandActions(list []string) ([]string, []bool) {
+	var res []string
+	var fromRole []bool
+	for _, act := range list {
+		neg := strings.HasPrefix(act, "!")
+		name := strings.TrimPrefix(act, "!")
+		if !strings.HasPrefix(name, "@") {
+			res = append(res, act)
+			continue
+		}
+		if _, ok := rr.roles[name[1:]]; !ok {
+			if !rr.isCategory(name[1:]) {
+				diag.Error("%s: no such role or category\n", name)
+			}
+			res = append(res, act)
+			continue
+		}
+		role := rr.resolve(name[1:])
+		if role == nil {
+			continue
+		}
+		n := len(res)
+		if neg {
+			for _, a := range role.Allow {
+				if !strings.HasPrefix(a, "!") {
+					res = append(res, "!" + a)
+				}
+			}
+		} else {
+			for _, a := range role.Deny {
+				if !strings.HasPrefix(a, "!") {
+					res = append(res, "!" + a)
+				}
+			}
+			res = append(res, role.Allow...)
+		}
+		for len(fromRole) < n {
+			fromRole = append(fromRole, false)
+		}
+		for len(fromRole) < len(res) {
+			fromRole = append(fromRole, true)
+		}
+	}
+	return res, fromRole
+}
+
+func (rr *roleResolver) isCategory(name string) bool {
+	for _, cat := range rr.categories {
+		if cat == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge attributes of the role into the ACE.  Lists from the role are
+// appended to those of the ACE, so that the latter take precedence.
+// Single-valued attributes are copied only if not set in the ACE.
+// Actions allowed by the role don't override the ACE's Deny, even if
+// named explicitly (see ActionIsAllowed).
+func mergeACE(ace, role *ACE) {
+	if len(role.Allow) > 0 {
+		mask := make([]bool, len(ace.Allow), len(ace.Allow) + len(role.Allow))
+		copy(mask, ace.roleAllow)
+		for range role.Allow {
+			mask = append(mask, true)
+		}
+		ace.roleAllow = mask
+	}
+	dst := reflect.ValueOf(ace).Elem()
+	src := reflect.ValueOf(role).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		switch dst.Type().Field(i).Name {
+		case "Id", "User", "Host", "Role", "Order", "roleAllow":
+			continue
+		}
+		d := dst.Field(i)
+		s := src.Field(i)
+		switch d.Kind() {
+		case reflect.Slice:
+			if s.Len() > 0 {
+				// Make a new slice: the original one may be
+				// shared with the configuration.
+				v := reflect.MakeSlice(d.Type(), 0, d.Len() + s.Len())
+				v = reflect.AppendSlice(v, d)
+				d.Set(reflect.AppendSlice(v, s))
+			}
+		case reflect.Ptr:
+			if d.IsNil() {
+				d.Set(s)
+			}
+		}
+	}
+}
+
+// Expand role references in each ACE of the ACL.  Categories lists
+// the names of built-in action categories.
+func (acl ACL) ExpandRoles(roles map[string]ACE, categories []string) {
+	rr := &roleResolver{
+		roles: roles,
+		categories: categories,
+		done: make(map[string]*ACE),
+		busy: make(map[string]bool),
+	}
+	for i := range acl {
+		rr.expand(&acl[i])
+	}
+}
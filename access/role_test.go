@@ -0,0 +1,115 @@
+package access
+
+import (
+	"reflect"
+	"testing"
+)
+
+var categories = []string{"readonly", "lifecycle", "exec"}
+
+func TestExpandRoles(t *testing.T) {
+	mem := int64(1024)
+	ownMem := int64(512)
+	roles := map[string]ACE{
+		"ops": {
+			Allow: []string{"ContainerStart", "ContainerStop"},
+			Deny: []string{"ContainerExec"},
+		},
+		"viewer": {
+			Allow: []string{"@readonly"},
+			MaxMemory: &mem,
+		},
+		"base": {
+			Role: []string{"viewer"},
+			Allow: []string{"ImageList"},
+		},
+		"loop": {
+			Role: []string{"loop"},
+			Allow: []string{"ImageList"},
+		},
+	}
+	for _, tc := range []struct {
+		name string
+		ace ACE
+		want ACE
+	}{
+		{ "action reference",
+		  ACE{Allow: []string{"@ops"}},
+		  ACE{Allow: []string{"!ContainerExec", "ContainerStart", "ContainerStop"},
+		      roleAllow: []bool{true, true, true}} },
+		{ "negated reference",
+		  ACE{Allow: []string{"!@ops", "ALL"}},
+		  ACE{Allow: []string{"!ContainerStart", "!ContainerStop", "ALL"},
+		      roleAllow: []bool{true, true}} },
+		{ "category",
+		  ACE{Allow: []string{"@readonly"}, Deny: []string{"@exec"}},
+		  ACE{Allow: []string{"@readonly"}, Deny: []string{"@exec"}} },
+		{ "unknown reference",
+		  ACE{Allow: []string{"@nosuch", "ImageList"}},
+		  ACE{Allow: []string{"@nosuch", "ImageList"}} },
+		{ "nested role",
+		  ACE{Role: []string{"base"}},
+		  ACE{Role: []string{"base"},
+		      Allow: []string{"ImageList", "@readonly"},
+		      MaxMemory: &mem,
+		      roleAllow: []bool{true, true}} },
+		{ "own value takes precedence",
+		  ACE{Role: []string{"viewer"}, Allow: []string{"ContainerList"},
+		      MaxMemory: &ownMem},
+		  ACE{Role: []string{"viewer"},
+		      Allow: []string{"ContainerList", "@readonly"},
+		      MaxMemory: &ownMem,
+		      roleAllow: []bool{false, true}} },
+		{ "self reference",
+		  ACE{Role: []string{"loop"}},
+		  ACE{Role: []string{"loop"}, Allow: []string{"ImageList"},
+		      roleAllow: []bool{true}} },
+		{ "unknown role",
+		  ACE{Role: []string{"none"}, Allow: []string{"ImageList"}},
+		  ACE{Role: []string{"none"}, Allow: []string{"ImageList"}} },
+	} {
+		acl := ACL{tc.ace}
+		acl.ExpandRoles(roles, categories)
+		if !reflect.DeepEqual(acl[0], tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, acl[0], tc.want)
+		}
+	}
+}
+
+func TestRoleActionPrecedence(t *testing.T) {
+	roles := map[string]ACE{
+		"ops": { Allow: []string{"ContainerExec", "ContainerStart"} },
+	}
+	for _, tc := range []struct {
+		ace ACE
+		action string
+		categories []string
+		want bool
+	}{
+		// Deny of the entry overrides actions named by the role
+		{ ACE{Id: "a", Role: []string{"ops"}, Deny: []string{"@exec"}},
+		  "ContainerExec", []string{"exec"}, false },
+		{ ACE{Id: "a", Role: []string{"ops"}, Deny: []string{"@exec"}},
+		  "ContainerStart", []string{"lifecycle"}, true },
+		// Actions named by the entry itself still override its Deny
+		{ ACE{Id: "a", Role: []string{"ops"}, Allow: []string{"ContainerExec"},
+		      Deny: []string{"@exec"}},
+		  "ContainerExec", []string{"exec"}, true },
+		// Deny also overrides actions of a role referred to in Allow
+		{ ACE{Id: "a", Allow: []string{"@ops"}, Deny: []string{"@exec"}},
+		  "ContainerExec", []string{"exec"}, false },
+		{ ACE{Id: "a", Allow: []string{"@ops"}, Deny: []string{"@exec"}},
+		  "ContainerStart", []string{"lifecycle"}, true },
+		// Unless the entry names them itself as well
+		{ ACE{Id: "a", Allow: []string{"@ops", "ContainerExec"},
+		      Deny: []string{"@exec"}},
+		  "ContainerExec", []string{"exec"}, true },
+	} {
+		acl := ACL{tc.ace}
+		acl.ExpandRoles(roles, categories)
+		if got, _ := acl.ActionIsAllowed(tc.action, tc.categories); got != tc.want {
+			t.Errorf("ActionIsAllowed(%s) on %+v = %v, want %v",
+				tc.action, acl[0], got, tc.want)
+		}
+	}
+}
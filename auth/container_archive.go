@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"path"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Return the path parameter of the archive request in canonical form.
+// Docker treats paths in the container as relative to its root
+// directory.
+func archivePath(req authorization.Request) (string, error) {
+	query, err := RequestQuery(req)
+	if err != nil {
+		return "", err
+	}
+	return path.Clean("/" + query.Get("path")), nil
+}
+
+// Used for ContainerArchive and ContainerArchiveInfo requests, which
+// read files from the container.
+func ContainerArchiveAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	name, err := archivePath(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Copy from container: %s\n", name)
+	res, id := acl.CopyFromIsAllowed(name)
+	diag.Trace("%s: copying from %s is %s by %s\n",
+		req.User, name, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: "copying from " + name + " is not allowed"}
+	}
+	return authorization.Response{Allow: true}
+}
+
+func ContainerExportAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	res, id := acl.ExportIsAllowed()
+	diag.Trace("%s: exporting container is %s by %s\n",
+		req.User, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: "exporting containers is not allowed"}
+	}
+	return authorization.Response{Allow: true}
+}
+
+func PutContainerArchiveAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	name, err := archivePath(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Copy to container: %s\n", name)
+	res, id := acl.CopyToIsAllowed(name)
+	diag.Trace("%s: copying to %s is %s by %s\n",
+		req.User, name, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: "copying to " + name + " is not allowed"}
+	}
+	return authorization.Response{Allow: true}
+}
@@ -1,13 +1,14 @@
 package auth
 
 import (
-	"fmt"
+	"sort"
 	"strings"
 	"bytes"
 	"encoding/json"
 	"github.com/docker/go-plugins-helpers/authorization"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
 	"sargon/diag"
 	"sargon/access"
 )	
@@ -15,6 +16,10 @@ import (
 type createRequest struct {
 	*container.Config
 	HostConfig       *container.HostConfig
+	NetworkingConfig *network.NetworkingConfig
+	// Set if the request is converted from a service spec (see
+	// serviceCreateRequest).
+	service          bool
 }
 
 func ContainerCreateAuth (acl access.ACL, req authorization.Request) authorization.Response {
@@ -23,6 +28,15 @@ func ContainerCreateAuth (acl access.ACL, req authorization.Request) authorizati
 	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(body); err != nil {
 		return authorization.Response{Err: err.Error()}
 	}
+
+	query, err := RequestQuery(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	if res, msg := checkResourceName(acl, "container", query.Get("name"), req.User); res == false {
+		diag.Debug("DENY: %s\n", msg)
+		return authorization.Response{Msg: msg}
+	}
 		
 	if res, msg := AllowCreate(acl, body, req.User); res == false {
 		diag.Debug("DENY: %s\n", msg)
@@ -32,6 +46,16 @@ func ContainerCreateAuth (acl access.ACL, req authorization.Request) authorizati
 }
 
 func AllowCreate(acl access.ACL, body *createRequest, username string) (bool, string) {
+	// Check image
+	if body.Config != nil {
+		res, id := acl.ImageIsAllowed(body.Image)
+		diag.Trace("%s: using image %s is %s by %s\n",
+		      username, body.Image, access.Resolution(res), id)
+		if ! res {
+			return false, "image " + body.Image + " is not allowed"
+		}
+	}
+
 	// Check if privileged containers are allowed
 	if body.HostConfig.Privileged {
 		res, id := acl.CreatePrivilegedIsAllowed()
@@ -42,6 +66,54 @@ func AllowCreate(acl access.ACL, body *createRequest, username string) (bool, st
 		}
 	}
 
+	// Check user
+	if body.Config != nil {
+		if ok, msg := checkContainerUser(acl, body.User, username); !ok {
+			return false, msg
+		}
+	}
+
+	// Check labels
+	var labels map[string]string
+	if body.Config != nil {
+		labels = body.Labels
+	}
+	if ok, msg := checkLabels(acl, labels, username); !ok {
+		return false, msg
+	}
+
+	// Check host namespaces and namespaces of other containers
+	for _, ns := range []struct {
+		name string
+		host bool
+		container string
+	}{
+		{ access.NamespaceNetwork, body.HostConfig.NetworkMode.IsHost(),
+		  body.HostConfig.NetworkMode.ConnectedContainer() },
+		{ access.NamespacePid, body.HostConfig.PidMode.IsHost(),
+		  body.HostConfig.PidMode.Container() },
+		{ access.NamespaceIpc, body.HostConfig.IpcMode.IsHost(),
+		  body.HostConfig.IpcMode.Container() },
+		{ access.NamespaceUts, body.HostConfig.UTSMode.IsHost(), "" },
+		{ access.NamespaceUserns, body.HostConfig.UsernsMode.IsHost(), "" },
+		{ access.NamespaceCgroupns, body.HostConfig.CgroupnsMode.IsHost(), "" },
+	} {
+		if ns.host {
+			if ok, msg := checkHostNamespace(acl, ns.name, username); !ok {
+				return false, msg
+			}
+		} else if ns.container != "" {
+			if ok, msg := checkContainerNamespace(acl, ns.name, ns.container, username); !ok {
+				return false, msg
+			}
+		}
+	}
+
+	// Check security options
+	if ok, msg := checkSecurityOpt(acl, body.HostConfig.SecurityOpt, true, username); !ok {
+		return false, msg
+	}
+
 	// Check capabilities
 	for _, cap := range body.HostConfig.CapAdd {
 		res, id := acl.CapIsAllowed(cap)
@@ -52,9 +124,51 @@ func AllowCreate(acl access.ACL, body *createRequest, username string) (bool, st
 		}
 	}
 
+	// Check published ports
+	if ok, msg := checkPortBindings(acl, body.HostConfig, username); !ok {
+		return false, msg
+	}
+
+	// Check networks
+	if ok, msg := checkNetworks(acl, body.HostConfig.NetworkMode, body.NetworkingConfig, username); !ok {
+		return false, msg
+	}
+
+	// Check the driver of volumes created implicitly, e.g. for
+	// named volumes in binds
+	if driver := body.HostConfig.VolumeDriver; driver != "" {
+		if ok, msg := checkVolumeDriverName(acl, driver, username); !ok {
+			return false, msg
+		}
+		if _, ok := knownDrivers[NormalizeVolumeDriver(driver)]; !ok {
+			return false, "volume driver " + driver + " is not supported"
+		}
+	}
+
+	// Check containers to mount volumes from (NAME[:ro|rw])
+	for _, from := range body.HostConfig.VolumesFrom {
+		ref := strings.SplitN(from, ":", 2)[0]
+		if ok, msg := checkContainerAccess(acl, "mounting volumes of", ref, username); !ok {
+			return false, msg
+		}
+	}
+
+	// Check anonymous volumes
+	if body.Config != nil && len(body.Config.Volumes) > 0 {
+		if ok, msg := checkResourceName(acl, "volume", "", username); !ok {
+			return false, msg
+		}
+	}
+
 	// Check binds (old API)
 	for _, b := range body.HostConfig.Binds {
 		a := strings.SplitN(b, ":", 2)
+		if access.IsVolumeName(a[0]) {
+			if ok, msg := checkResourceName(acl, "volume", a[0], username); !ok {
+				return false, msg
+			}
+			continue
+		}
 		res, id := acl.MountIsAllowed(a[0],
 			                      strings.HasSuffix(a[1], ":ro"))
 		diag.Trace("%s: binding to %s is %s by %s\n",
@@ -66,31 +180,192 @@ func AllowCreate(acl access.ACL, body *createRequest, username string) (bool, st
 	
 	// Check mounts (new API)
 	for _, m := range body.HostConfig.Mounts {
-		if m.Type == mount.TypeBind {
+		switch m.Type {
+		case mount.TypeBind:
 			res, id := acl.MountIsAllowed(m.Source, m.ReadOnly)
 			diag.Trace("%s: mounting %s is %s by %s\n",
 			      username, m.Source, access.Resolution(res), id)
 			if ! res {
 				return false, "mounting " + m.Source + " is not allowed"
 			}
+		case mount.TypeVolume:
+			if ok, msg := checkResourceName(acl, "volume", m.Source, username); !ok {
+				return false, msg
+			}
+			if ok, msg, err := checkMountVolumeOptions(acl, m, username); err != nil {
+				return false, err.Error()
+			} else if !ok {
+				return false, msg
+			}
 		}
 	}
 	
-	// Check requested memory sizes
-	ok, lim, id := acl.CheckMaxMemory("sargonMaxMemory", body.HostConfig.Memory)
-	diag.Trace("%s: setting MaxMemory=%d is %s by %s\n",
-	      username, body.HostConfig.Memory, access.Resolution(ok), id)
-	if !ok {
-		return false, "memory limit must be lower than or equal to " + fmt.Sprintf("%v",lim)
+	// Check devices
+	for _, dev := range body.HostConfig.Devices {
+		res, id := acl.DeviceIsAllowed(dev.PathOnHost)
+		diag.Trace("%s: using device %s is %s by %s\n",
+		      username, dev.PathOnHost, access.Resolution(res), id)
+		if ! res {
+			return false, "using device " + dev.PathOnHost + " is not allowed"
+		}
 	}
 
-	ok, lim, id = acl.CheckMaxMemory("sargonMaxKernelMemory", body.HostConfig.KernelMemory)
-	diag.Trace("%s: MaxKernelMemory=%d is %s by %s\n",
-	      username, body.HostConfig.Memory, access.Resolution(ok), id)
-	if !ok {
-		return false, "kernel memory limit must be lower than or equal to " + fmt.Sprintf("%v",lim)
-        }
+	for _, rule := range body.HostConfig.DeviceCgroupRules {
+		res, id := acl.DeviceCgroupRuleIsAllowed(rule)
+		diag.Trace("%s: device cgroup rule %s is %s by %s\n",
+		      username, rule, access.Resolution(res), id)
+		if ! res {
+			return false, "device cgroup rule " + rule + " is not allowed"
+		}
+	}
+
+	for _, dr := range body.HostConfig.DeviceRequests {
+		for _, r := range deviceRequestNames(dr) {
+			res, id := acl.DeviceRequestIsAllowed(r)
+			diag.Trace("%s: device request %s is %s by %s\n",
+			      username, r, access.Resolution(res), id)
+			if ! res {
+				return false, "device request " + r + " is not allowed"
+			}
+		}
+	}
+
+	// Check extra hosts
+	for _, host := range body.HostConfig.ExtraHosts {
+		if ok, msg := checkExtraHost(acl, host, username); !ok {
+			return false, msg
+		}
+	}
+
+	// Check kernel parameters
+	if ok, msg := checkSysctls(acl, body.HostConfig.Sysctls, username); !ok {
+		return false, msg
+	}
+
+	// Check restart policy
+	if ok, msg := checkRestartPolicy(acl, body.HostConfig.RestartPolicy, username); !ok {
+		return false, msg
+	}
+
+	// Check requested resources
+	if ok, msg := checkResources(acl, body.HostConfig, body.service, username); !ok {
+		return false, msg
+	}
 
 	return true, "Ok"
 }
-	
+
+// Check kernel parameters the container sets.
+func checkSysctls(acl access.ACL, sysctls map[string]string, username string) (bool, string) {
+	names := make([]string, 0, len(sysctls))
+	for name := range sysctls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sysctl := name + "=" + sysctls[name]
+		res, id := acl.SysctlIsAllowed(sysctl)
+		diag.Trace("%s: setting %s is %s by %s\n",
+			username, sysctl, access.Resolution(res), id)
+		if !res {
+			return false, "setting kernel parameter " + sysctl + " is not allowed"
+		}
+	}
+	return true, "Ok"
+}
+
+// Name used to match requests that don't specify the user.
+const DefaultUser = "default"
+
+func checkContainerUser(acl access.ACL, user, username string) (bool, string) {
+	if user == "" {
+		user = DefaultUser
+	}
+	res, id := acl.ContainerUserIsAllowed(user)
+	diag.Trace("%s: running container as user %s is %s by %s\n",
+		username, user, access.Resolution(res), id)
+	if !res {
+		return false, "running containers as user " + user + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+func checkHostNamespace(acl access.ACL, ns, username string) (bool, string) {
+	res, id := acl.HostNamespaceIsAllowed(ns)
+	diag.Trace("%s: using host %s namespace is %s by %s\n",
+		username, ns, access.Resolution(res), id)
+	if !res {
+		return false, "using host " + ns + " namespace is not allowed"
+	}
+	return true, "Ok"
+}
+
+// Return the owner of the container with the given name or ID, or
+// empty string if not known.  Set by the server when ownership
+// tracking is enabled, nil otherwise.
+var ContainerOwner func(ref string) string
+
+// Return the owner of the container, or empty string if not known or
+// not tracked.
+func containerOwner(ref string) string {
+	if ContainerOwner == nil {
+		return ""
+	}
+	return ContainerOwner(ref)
+}
+
+// Check if the user may access the contents of another container, e.g.
+// mount its volumes or commit it.  This is allowed if the user owns
+// that container or ownership is not tracked.  Otherwise, it is allowed
+// only if the user may create privileged containers.
+func checkContainerAccess(acl access.ACL, op, ref, username string) (bool, string) {
+	if ContainerOwner == nil {
+		return true, "Ok"
+	}
+	owner := ContainerOwner(ref)
+	if owner == username {
+		diag.Trace("%s: %s own container %s\n", username, op, ref)
+		return true, "Ok"
+	}
+	res, id := acl.CreatePrivilegedIsAllowed()
+	diag.Trace("%s: %s container %s owned by %q is %s by %s\n",
+		username, op, ref, owner, access.Resolution(res), id)
+	if !res {
+		return false, op + " container " + ref + " of another user is not allowed"
+	}
+	return true, "Ok"
+}
+
+// Check if the user may join the namespace of another container.  This
+// is allowed if the user owns that container.  Otherwise, it is allowed
+// only if the user may use the host namespace, because the container
+// can be running in it.
+func checkContainerNamespace(acl access.ACL, ns, ref, username string) (bool, string) {
+	if owner := containerOwner(ref); owner == username {
+		diag.Trace("%s: using %s namespace of own container %s\n",
+			username, ns, ref)
+		return true, "Ok"
+	}
+	res, id := acl.HostNamespaceIsAllowed(ns)
+	diag.Trace("%s: using %s namespace of container %s is %s by %s\n",
+		username, ns, ref, access.Resolution(res), id)
+	if !res {
+		return false, "using " + ns + " namespace of container " + ref + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+// Convert device request to a list of strings in the form DRIVER:CAP,
+// one for each requested capability.  If no capabilities are
+// requested, return the driver name alone.
+func deviceRequestNames(dr container.DeviceRequest) (names []string) {
+	for _, caps := range dr.Capabilities {
+		for _, cap := range caps {
+			names = append(names, dr.Driver + ":" + cap)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{dr.Driver}
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Fields of the exec request body subject to checking.
+type execRequest struct {
+	User string
+	Privileged bool
+	Env []string
+	Cmd []string
+}
+
+func ContainerExecAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	body := &execRequest{}
+	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(body); err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Exec request: %#v\n", body)
+
+	if body.Privileged {
+		res, id := acl.ExecPrivilegedIsAllowed()
+		diag.Trace("%s: privileged exec is %s by %s\n",
+			req.User, access.Resolution(res), id)
+		if !res {
+			return authorization.Response{Msg: "you are not allowed to run privileged commands"}
+		}
+	}
+
+	user := body.User
+	if user == "" {
+		user = DefaultUser
+	}
+	res, id := acl.ExecUserIsAllowed(user)
+	diag.Trace("%s: exec as user %s is %s by %s\n",
+		req.User, user, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: "running commands as user " + user + " is not allowed"}
+	}
+
+	for _, env := range body.Env {
+		res, id := acl.ExecEnvIsAllowed(env)
+		diag.Trace("%s: exec environment %s is %s by %s\n",
+			req.User, env, access.Resolution(res), id)
+		if !res {
+			return authorization.Response{Msg: "environment setting " + env + " is not allowed"}
+		}
+	}
+
+	if len(body.Cmd) > 0 {
+		res, id := acl.ExecCommandIsAllowed(body.Cmd[0])
+		diag.Trace("%s: exec command %s is %s by %s\n",
+			req.User, body.Cmd[0], access.Resolution(res), id)
+		if !res {
+			return authorization.Response{Msg: "command " + body.Cmd[0] + " is not allowed"}
+		}
+	}
+
+	return authorization.Response{Allow: true}
+}
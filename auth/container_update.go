@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"github.com/docker/docker/api/types/container"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Check if the restart policy is allowed.
+func checkRestartPolicy(acl access.ACL, policy container.RestartPolicy, username string) (bool, string) {
+	name := string(policy.Name)
+	if name == "" {
+		name = string(container.RestartPolicyDisabled)
+	}
+	res, id := acl.RestartPolicyIsAllowed(name)
+	diag.Trace("%s: restart policy %s is %s by %s\n",
+		username, name, access.Resolution(res), id)
+	if !res {
+		return false, "restart policy " + name + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+func ContainerUpdateAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	body := &container.UpdateConfig{}
+	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(body); err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Update container request: %#v\n", body)
+
+	if body.RestartPolicy.Name != "" {
+		if ok, msg := checkRestartPolicy(acl, body.RestartPolicy, req.User); !ok {
+			return authorization.Response{Msg: msg}
+		}
+	}
+
+	if ok, msg := checkUpdateResources(acl, &body.Resources, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+	return authorization.Response{Allow: true}
+}
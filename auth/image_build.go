@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"github.com/docker/docker/api/types/container"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Strip tag from the image reference given in the t parameter of the
+// build request.
+func buildRepository(tag string) string {
+	ref := access.ParseImageRef(tag)
+	if ref.Tag != "" {
+		tag = strings.TrimSuffix(tag, ":" + ref.Tag)
+	}
+	return tag
+}
+
+// Return value of the integer query parameter, 0 if not set.
+func queryInt(val string) (int64, error) {
+	if val == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(val, 10, 64)
+}
+
+// Check the extra host entry (--add-host).
+func checkExtraHost(acl access.ACL, host, username string) (bool, string) {
+	host = access.NormalizeExtraHost(host)
+	res, id := acl.ExtraHostIsAllowed(host)
+	diag.Trace("%s: adding host %s is %s by %s\n",
+		username, host, access.Resolution(res), id)
+	if !res {
+		return false, "host entry " + host + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+// Return resource settings requested by the build.
+func buildResources(query map[string][]string) (*container.HostConfig, error) {
+	hc := &container.HostConfig{}
+	get := func (name string) string {
+		if v := query[name]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	for _, p := range []struct {
+		name string
+		val *int64
+	}{
+		{ "memory", &hc.Memory },
+		{ "memswap", &hc.MemorySwap },
+		{ "cpushares", &hc.CPUShares },
+		{ "cpuquota", &hc.CPUQuota },
+		{ "cpuperiod", &hc.CPUPeriod },
+		{ "shmsize", &hc.ShmSize },
+	} {
+		n, err := queryInt(get(p.name))
+		if err != nil {
+			return nil, err
+		}
+		*p.val = n
+	}
+	hc.CpusetCpus = get("cpusetcpus")
+	hc.CpusetMems = get("cpusetmems")
+	if s := get("ulimits"); s != "" {
+		if err := json.Unmarshal([]byte(s), &hc.Ulimits); err != nil {
+			return nil, err
+		}
+	}
+	return hc, nil
+}
+
+func ImageBuildAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	query, err := RequestQuery(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Build image request: %#v\n", query)
+
+	if container.NetworkMode(query.Get("networkmode")).IsHost() {
+		if ok, msg := checkHostNamespace(acl, access.NamespaceNetwork, req.User); !ok {
+			return authorization.Response{Msg: msg}
+		}
+	}
+
+	if remote := query.Get("remote"); remote != "" {
+		res, id := acl.BuildRemoteIsAllowed(remote)
+		diag.Trace("%s: building from %s is %s by %s\n",
+			req.User, remote, access.Resolution(res), id)
+		if !res {
+			return authorization.Response{Msg: "building from " + remote + " is not allowed"}
+		}
+	}
+
+	if platform := query.Get("platform"); platform != "" {
+		res, id := acl.BuildPlatformIsAllowed(platform)
+		diag.Trace("%s: building for platform %s is %s by %s\n",
+			req.User, platform, access.Resolution(res), id)
+		if !res {
+			return authorization.Response{Msg: "platform " + platform + " is not allowed"}
+		}
+	}
+
+	for _, host := range query["extrahosts"] {
+		if ok, msg := checkExtraHost(acl, host, req.User); !ok {
+			return authorization.Response{Msg: msg}
+		}
+	}
+
+	// Builds can't be run with no-new-privileges, so don't require
+	// it
+	if ok, msg := checkSecurityOpt(acl, query["securityopt"], false, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+
+	// Custom cgroup parent escapes the limits set on the default one
+	if parent := query.Get("cgroupparent"); parent != "" {
+		res, id := acl.CreatePrivilegedIsAllowed()
+		diag.Trace("%s: building in cgroup %s is %s by %s\n",
+			req.User, parent, access.Resolution(res), id)
+		if !res {
+			return authorization.Response{Msg: "cgroup parent " + parent + " is not allowed"}
+		}
+	}
+
+	hc, err := buildResources(query)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	if ok, msg := checkCpusets(acl, &hc.Resources, false, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+	for _, u := range hc.Ulimits {
+		ok, lim, id := acl.CheckUlimit(u.Name, u.Soft, u.Hard)
+		diag.Trace("%s: setting ulimit %s=%d:%d is %s by %s\n",
+			req.User, u.Name, u.Soft, u.Hard, access.Resolution(ok), id)
+		if !ok {
+			return authorization.Response{Msg: "ulimit " + u.Name + " exceeds " + lim}
+		}
+	}
+	limits := containerLimits(hc)
+	for i := range limits {
+		// Build containers are short-lived: don't require limits
+		limits[i].unlimited = false
+	}
+	if ok, msg := checkLimits(acl, limits, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+
+	for _, tag := range query["t"] {
+		if res := checkRepository(acl, req, buildRepository(tag), "tagging"); !res.Allow {
+			return res
+		}
+	}
+	return authorization.Response{Allow: true}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"strings"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Build image reference from the fromImage and tag query parameters
+// of the ImageCreate request.
+func pullImageName(image, tag string) string {
+	if tag == "" || strings.ContainsAny(image, "@") {
+		return image
+	}
+	ref := access.ParseImageRef(image)
+	if ref.Tag != "" {
+		return image
+	}
+	if strings.Contains(tag, ":") {
+		// Digest
+		return image + "@" + tag
+	}
+	return image + ":" + tag
+}
+
+func ImageCreateAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	query, err := RequestQuery(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	image := query.Get("fromImage")
+	if image == "" {
+		// Import from source: check the target repository, if any
+		repo := query.Get("repo")
+		if repo == "" {
+			return authorization.Response{Allow: true}
+		}
+		diag.Debug("Import image request: %s\n", repo)
+		return checkRepository(acl, req, buildRepository(repo), "importing")
+	}
+	image = pullImageName(image, query.Get("tag"))
+	diag.Debug("Create image request: %s\n", image)
+
+	res, id := acl.RegistryIsAllowed(image)
+	diag.Trace("%s: pulling from registry of %s is %s by %s\n",
+		req.User, image, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: "pulling from registry " +
+			access.ParseImageRef(image).Registry + " is not allowed"}
+	}
+
+	res, id = acl.ImageIsAllowed(image)
+	diag.Trace("%s: pulling image %s is %s by %s\n",
+		req.User, image, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: "image " + image + " is not allowed"}
+	}
+	return authorization.Response{Allow: true}
+}
+
+// Image archive may contain any repository names, which are not known
+// until it is loaded.
+func ImageLoadAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	res, id := acl.LoadIsAllowed()
+	diag.Trace("%s: loading images is %s by %s\n",
+		req.User, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: "loading images is not allowed"}
+	}
+	return authorization.Response{Allow: true}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"regexp"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"sargon/access"
+	"sargon/diag"
+)
+
+var imageNameRe = regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/(.+?)/(?:push|tag)$`)
+
+// Extract image name from the URI of ImagePush or ImageTag request.
+func requestImageName(req authorization.Request) (string, error) {
+	path, err := RequestPath(req)
+	if err != nil {
+		return "", err
+	}
+	if res := imageNameRe.FindStringSubmatch(path); res != nil {
+		return res[1], nil
+	}
+	return "", errors.New("can't get image name from " + path)
+}
+
+// Check if repo can be used as the target of the given operation.
+func checkRepository(acl access.ACL, req authorization.Request, repo, op string) authorization.Response {
+	res, id := acl.RepositoryIsAllowed(repo)
+	diag.Trace("%s: %s to repository %s is %s by %s\n",
+		req.User, op, repo, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: op + " to repository " + repo + " is not allowed"}
+	}
+	return authorization.Response{Allow: true}
+}
+
+func ImagePushAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	name, err := requestImageName(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Push image request: %s\n", name)
+	return checkRepository(acl, req, name, "pushing")
+}
+
+// Used for ImageTag requests, and by ImageCommitAuth: both take the
+// target repository name in the repo query parameter.
+func ImageTagAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	query, err := RequestQuery(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	repo := query.Get("repo")
+	if repo == "" {
+		return authorization.Response{Allow: true}
+	}
+	diag.Debug("Tag image request: %s\n", repo)
+	return checkRepository(acl, req, repo, "tagging")
+}
+
+func ImageCommitAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	query, err := RequestQuery(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	if ref := query.Get("container"); ref != "" {
+		if ok, msg := checkContainerAccess(acl, "committing", ref, req.User); !ok {
+			return authorization.Response{Msg: msg}
+		}
+	}
+	return ImageTagAuth(acl, req)
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"sort"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Check labels of the resource being created against the ACL: all
+// required labels must be present and have allowed values, and none of
+// the labels may be forbidden.
+func checkLabels(acl access.ACL, labels map[string]string, username string) (bool, string) {
+	return matchLabels(acl, labels, true, username)
+}
+
+// Check labels of service task containers.  Unlike with checkLabels,
+// required labels may be missing (they are checked on the service
+// itself), but if present, they must have allowed values.
+func checkTaskLabels(acl access.ACL, labels map[string]string, username string) (bool, string) {
+	return matchLabels(acl, labels, false, username)
+}
+
+func matchLabels(acl access.ACL, labels map[string]string, strict bool, username string) (bool, string) {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	required, id := acl.RequiredLabels()
+	for _, req := range required {
+		found := false
+		for _, key := range keys {
+			if match, ok := access.LabelSatisfies(req, key, labels[key]); match {
+				diag.Trace("%s: label %s=%s is %s by %s\n",
+					username, key, labels[key],
+					access.Resolution(ok), id)
+				if !ok {
+					return false, "label " + req + " is required"
+				}
+				found = true
+				break
+			}
+		}
+		if !found && strict {
+			diag.Trace("%s: missing label %s required by %s\n",
+				username, req, id)
+			return false, "label " + req + " is required"
+		}
+	}
+
+	for _, key := range keys {
+		res, id := acl.LabelIsAllowed(key)
+		if !res {
+			diag.Trace("%s: label %s is %s by %s\n",
+				username, key, access.Resolution(res), id)
+			return false, "label " + key + " is not allowed"
+		}
+	}
+	return true, "Ok"
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"github.com/docker/docker/api/types/container"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Requested value of a resource subject to a limit.
+type resourceLimit struct {
+	kw string       // Name of the limit in ACE (see access.ACE.Limit)
+	descr string    // Human-readable description
+	flag string     // Docker option used to set the limit
+	val int64       // Requested value
+	unlimited bool  // True if no limit is requested
+}
+
+// Default CFS scheduler period, used when CpuQuota is given without
+// CpuPeriod.
+const defaultCpuPeriod = 100000
+
+// Return the list of resource limits requested by the container.
+func containerLimits(hc *container.HostConfig) []resourceLimit {
+	swap := hc.MemorySwap
+	if swap < 0 {
+		// Unlimited swap
+		swap = math.MaxInt64
+	} else if swap == 0 {
+		// Docker defaults to twice the memory limit
+		swap = 2 * hc.Memory
+	}
+	limits := []resourceLimit{
+		{ kw: "MaxMemory", descr: "memory limit", flag: "--memory",
+		  val: hc.Memory, unlimited: hc.Memory <= 0 },
+		{ kw: "MaxKernelMemory", descr: "kernel memory limit", flag: "--kernel-memory",
+		  val: hc.KernelMemory, unlimited: hc.KernelMemory <= 0 },
+		{ kw: "MaxMemorySwap", descr: "memory+swap limit", flag: "--memory-swap",
+		  val: swap, unlimited: hc.MemorySwap < 0 || (hc.MemorySwap == 0 && hc.Memory <= 0) },
+		{ kw: "MaxMemoryReservation", descr: "memory reservation", flag: "--memory-reservation",
+		  val: hc.MemoryReservation, unlimited: hc.MemoryReservation <= 0 },
+		{ kw: "MaxShmSize", descr: "size of /dev/shm", flag: "--shm-size",
+		  val: hc.ShmSize },
+		{ kw: "MaxNanoCpus", descr: "number of CPUs (in units of 1e-9 CPUs)", flag: "--cpus",
+		  val: hc.NanoCPUs, unlimited: hc.NanoCPUs <= 0 && hc.CPUQuota <= 0 },
+		{ kw: "MaxCpuShares", descr: "CPU shares", flag: "--cpu-shares",
+		  val: hc.CPUShares },
+	}
+	if hc.CPUQuota > 0 {
+		period := hc.CPUPeriod
+		if period <= 0 {
+			period = defaultCpuPeriod
+		}
+		limits = append(limits, resourceLimit{
+			kw: "MaxNanoCpus",
+			descr: "CPU quota (in units of 1e-9 CPUs)",
+			flag: "--cpu-quota",
+			val: int64(float64(hc.CPUQuota) * 1e9 / float64(period)),
+		})
+	}
+	var pids int64
+	if hc.PidsLimit != nil && *hc.PidsLimit > 0 {
+		pids = *hc.PidsLimit
+	}
+	limits = append(limits, resourceLimit{
+		kw: "MaxPidsLimit",
+		descr: "PIDs limit",
+		flag: "--pids-limit",
+		val: pids,
+		unlimited: pids == 0,
+	})
+	return limits
+}
+
+func checkLimits(acl access.ACL, limits []resourceLimit, username string) (bool, string) {
+	for _, l := range limits {
+		if l.unlimited {
+			if req, id := acl.LimitIsRequired(l.kw); req {
+				diag.Trace("%s: %s is required by %s\n",
+					username, l.kw, id)
+				return false, l.descr + " must be set (use " + l.flag + ")"
+			}
+		}
+		ok, lim, id := acl.CheckLimit(l.kw, l.val)
+		diag.Trace("%s: setting %s=%d is %s by %s\n",
+			username, l.kw, l.val, access.Resolution(ok), id)
+		if !ok {
+			return false, l.descr + " must be lower than or equal to " + fmt.Sprintf("%v", lim)
+		}
+	}
+	return true, "Ok"
+}
+
+// Check requested cpusets.  If required is true, fail if the cpuset
+// is not given, but the ACL requires it.
+func checkCpusets(acl access.ACL, r *container.Resources, required bool, username string) (bool, string) {
+	for _, cs := range []struct {
+		kw, flag, val string
+	}{
+		{ "CpusetCpus", "--cpuset-cpus", r.CpusetCpus },
+		{ "CpusetMems", "--cpuset-mems", r.CpusetMems },
+	} {
+		if cs.val == "" {
+			if !required {
+				continue
+			}
+			if req, id := acl.CpusetIsRequired(cs.kw); req {
+				diag.Trace("%s: %s is required by %s\n",
+					username, cs.kw, id)
+				return false, cs.kw + " must be set (use " + cs.flag + ")"
+			}
+			continue
+		}
+		ok, id := acl.CpusetIsAllowed(cs.kw, cs.val)
+		diag.Trace("%s: setting %s=%s is %s by %s\n",
+			username, cs.kw, cs.val, access.Resolution(ok), id)
+		if !ok {
+			return false, cs.kw + " " + cs.val + " is not allowed"
+		}
+	}
+	return true, "Ok"
+}
+
+// Check cpusets, ulimits and resource limits of the container.  If
+// service is true, the container is a service task: swarm can't set
+// kernel memory limit and cpusets, so these are not required.
+func checkResources(acl access.ACL, hc *container.HostConfig, service bool, username string) (bool, string) {
+	if ok, msg := checkCpusets(acl, &hc.Resources, !service, username); !ok {
+		return false, msg
+	}
+
+	for _, u := range hc.Ulimits {
+		ok, lim, id := acl.CheckUlimit(u.Name, u.Soft, u.Hard)
+		diag.Trace("%s: setting ulimit %s=%d:%d is %s by %s\n",
+			username, u.Name, u.Soft, u.Hard, access.Resolution(ok), id)
+		if !ok {
+			return false, "ulimit " + u.Name + " exceeds " + lim
+		}
+	}
+
+	required := acl.RequiredUlimits()
+	names := make([]string, 0, len(required))
+	for name := range required {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		id := required[name]
+		found := false
+		for _, u := range hc.Ulimits {
+			if u.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diag.Trace("%s: ulimit %s is required by %s\n",
+				username, name, id)
+			return false, "ulimit " + name + " must be set (use --ulimit " + name + "=SOFT:HARD)"
+		}
+	}
+
+	limits := containerLimits(hc)
+	if service {
+		for i := range limits {
+			if limits[i].kw == "MaxKernelMemory" {
+				limits[i].unlimited = false
+			}
+		}
+	}
+	return checkLimits(acl, limits, username)
+}
+
+// Check resources of the container update request.  Zero values leave
+// the corresponding settings unchanged, so a limit is considered
+// missing only if it is explicitly removed, i.e. set to a negative
+// value.
+func checkUpdateResources(acl access.ACL, r *container.Resources, username string) (bool, string) {
+	if ok, msg := checkCpusets(acl, r, false, username); !ok {
+		return false, msg
+	}
+
+	// The number of CPUs can't be computed from the CPU quota or
+	// period alone, as the other one is that of the container.
+	if (r.CPUQuota > 0 && r.CPUPeriod == 0) || (r.CPUPeriod > 0 && r.CPUQuota == 0) {
+		if lim, id := acl.Limit("MaxNanoCpus"); lim != nil {
+			diag.Trace("%s: updating CPU quota or period alone is denied by %s\n",
+				username, id)
+			return false, "CPU quota and period must be set together (use --cpu-quota and --cpu-period)"
+		}
+	}
+
+	var limits []resourceLimit
+	for _, l := range containerLimits(&container.HostConfig{Resources: *r}) {
+		switch l.kw {
+		case "MaxMemory":
+			l.unlimited = r.Memory < 0
+		case "MaxNanoCpus":
+			l.unlimited = r.NanoCPUs < 0 || r.CPUQuota < 0
+		case "MaxMemorySwap":
+			if r.MemorySwap == 0 {
+				// Unchanged
+				continue
+			}
+			l.unlimited = r.MemorySwap < 0
+		case "MaxPidsLimit":
+			l.unlimited = r.PidsLimit != nil && *r.PidsLimit <= 0
+		default:
+			l.unlimited = false
+		}
+		limits = append(limits, l)
+	}
+	return checkLimits(acl, limits, username)
+}
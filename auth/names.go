@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"strings"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Check the name of the container, volume or network being created or
+// renamed.  Empty name means that docker will generate one.
+func checkResourceName(acl access.ACL, kind, name, username string) (bool, string) {
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		res, id := acl.AnonymousNameIsAllowed()
+		diag.Trace("%s: anonymous %s is %s by %s\n",
+			username, kind, access.Resolution(res), id)
+		if !res {
+			return false, kind + " name must be given"
+		}
+		return true, "Ok"
+	}
+	res, id := acl.ResourceNameIsAllowed(name)
+	diag.Trace("%s: %s name %s is %s by %s\n",
+		username, kind, name, access.Resolution(res), id)
+	if !res {
+		return false, kind + " name " + name + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+func ContainerRenameAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	query, err := RequestQuery(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	name := query.Get("name")
+	diag.Debug("Rename container request: %s\n", name)
+	if name == "" {
+		// Docker will reject the request anyway
+		return authorization.Response{Allow: true}
+	}
+	if ok, msg := checkResourceName(acl, "container", name, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+	return authorization.Response{Allow: true}
+}
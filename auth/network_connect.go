@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Return the name of the network identified by ref, or empty string if
+// not known.  Set by the server when ownership tracking is enabled.
+var NetworkName func(ref string) string
+
+// Return the name of the network if ref is the id of a known network,
+// otherwise ref itself.
+func networkName(ref string) string {
+	if NetworkName != nil {
+		if name := NetworkName(ref); name != "" {
+			return name
+		}
+	}
+	return ref
+}
+
+// Check if the container can be attached to the network.
+func checkNetwork(acl access.ACL, name, username string) (bool, string) {
+	res, id := acl.NetworkIsAllowed(name)
+	diag.Trace("%s: attaching to network %s is %s by %s\n",
+		username, name, access.Resolution(res), id)
+	if !res {
+		return false, "attaching to network " + name + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+// Check networks the container is to be attached to: the one given by
+// the network mode and the ones listed in the endpoint configuration.
+// Host network is checked separately, as the host namespace.  A
+// container sharing the network of another user's container joins
+// networks that can't be known in advance, so the mode itself
+// (container:REF) is checked.
+func checkNetworks(acl access.ACL, mode container.NetworkMode, nc *network.NetworkingConfig, username string) (bool, string) {
+	if mode == "" || mode.IsDefault() {
+		mode = network.NetworkBridge
+	}
+	if mode.IsContainer() {
+		if containerOwner(mode.ConnectedContainer()) != username {
+			if ok, msg := checkNetwork(acl, string(mode), username); !ok {
+				return false, msg
+			}
+		}
+	} else if mode.IsBridge() || mode.IsUserDefined() {
+		if ok, msg := checkNetwork(acl, string(mode), username); !ok {
+			return false, msg
+		}
+	}
+	if nc != nil {
+		for name := range nc.EndpointsConfig {
+			if ok, msg := checkNetwork(acl, name, username); !ok {
+				return false, msg
+			}
+		}
+	}
+	return true, "Ok"
+}
+
+var networkConnectRe = regexp.MustCompile(`^(?:/v\d+\.\d+)?/networks/(.+?)/connect$`)
+
+func NetworkConnectAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	path, err := RequestPath(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	res := networkConnectRe.FindStringSubmatch(path)
+	if res == nil {
+		return authorization.Response{Err: errors.New("can't get network name from " + path).Error()}
+	}
+	var body struct { Container string }
+	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(&body); err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Connect container %s to network %s\n", body.Container, res[1])
+
+	if ok, msg := checkNetwork(acl, res[1], req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+	if ok, msg := checkContainerAccess(acl, "connecting", body.Container, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+	return authorization.Response{Allow: true}
+}
+
+func NetworkDisconnectAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	var body struct { Container string }
+	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(&body); err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Disconnect container %s from network\n", body.Container)
+
+	if ok, msg := checkContainerAccess(acl, "disconnecting", body.Container, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+	return authorization.Response{Allow: true}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"github.com/docker/docker/api/types/network"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Fields of the network create request body subject to checking.
+type networkCreateRequest struct {
+	Name string
+	Driver string
+	Internal bool
+	Attachable bool
+	Ingress bool
+	IPAM *network.IPAM
+	Options map[string]string
+	Labels map[string]string
+}
+
+// Default network driver.
+const DefaultNetworkDriver = "bridge"
+
+func NetworkCreateAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	body := &networkCreateRequest{}
+	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(body); err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Create network request: %#v\n", body)
+
+	if ok, msg := checkResourceName(acl, "network", body.Name, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+
+	if ok, msg := checkLabels(acl, body.Labels, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+
+	driver := body.Driver
+	if driver == "" {
+		driver = DefaultNetworkDriver
+	}
+	res, id := acl.NetworkDriverIsAllowed(driver)
+	diag.Trace("%s: using network driver %s is %s by %s\n",
+		req.User, driver, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: "network driver " + driver + " is not allowed"}
+	}
+
+	if parent, ok := body.Options["parent"]; ok {
+		res, id := acl.NetworkParentIsAllowed(parent)
+		diag.Trace("%s: using parent interface %s is %s by %s\n",
+			req.User, parent, access.Resolution(res), id)
+		if !res {
+			return authorization.Response{Msg: "parent interface " + parent + " is not allowed"}
+		}
+	}
+
+	if body.IPAM != nil {
+		for _, conf := range body.IPAM.Config {
+			if conf.Subnet == "" {
+				continue
+			}
+			res, id := acl.NetworkSubnetIsAllowed(conf.Subnet)
+			diag.Trace("%s: using subnet %s is %s by %s\n",
+				req.User, conf.Subnet, access.Resolution(res), id)
+			if !res {
+				return authorization.Response{Msg: "subnet " + conf.Subnet + " is not allowed"}
+			}
+		}
+	}
+
+	for _, flag := range []struct {
+		name string
+		set bool
+	}{
+		{ access.NetworkInternal, body.Internal },
+		{ access.NetworkAttachable, body.Attachable },
+		{ access.NetworkIngress, body.Ingress },
+	} {
+		if !flag.set {
+			continue
+		}
+		res, id := acl.NetworkFlagIsAllowed(flag.name)
+		diag.Trace("%s: creating %s network is %s by %s\n",
+			req.User, flag.name, access.Resolution(res), id)
+		if !res {
+			return authorization.Response{Msg: "you are not allowed to create " + flag.name + " networks"}
+		}
+	}
+	return authorization.Response{Allow: true}
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Privilege requested by a plugin, as listed in the body of the
+// PluginPull and PluginUpgrade requests.
+type pluginPrivilege struct {
+	Name string
+	Value []string
+}
+
+// Check if the plugin can be installed from the remote reference.
+func checkPlugin(acl access.ACL, remote, username string) (bool, string) {
+	res, id := acl.PluginIsAllowed(remote)
+	diag.Trace("%s: installing plugin %s is %s by %s\n",
+		username, remote, access.Resolution(res), id)
+	if !res {
+		return false, "plugin " + remote + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+// Check the privilege requested by the plugin.  Unknown privileges
+// are denied.
+func checkPluginPrivilege(acl access.ACL, priv pluginPrivilege, username string) (bool, string) {
+	switch priv.Name {
+	case "network":
+		for _, val := range priv.Value {
+			if val == "host" {
+				return checkHostNamespace(acl, access.NamespaceNetwork, username)
+			}
+		}
+
+	case "host ipc namespace":
+		return checkHostNamespace(acl, access.NamespaceIpc, username)
+
+	case "host pid namespace":
+		return checkHostNamespace(acl, access.NamespacePid, username)
+
+	case "mount":
+		for _, src := range priv.Value {
+			res, id := acl.MountIsAllowed(src, false)
+			diag.Trace("%s: plugin mounting %s is %s by %s\n",
+				username, src, access.Resolution(res), id)
+			if !res {
+				return false, "mounting " + src + " is not allowed"
+			}
+		}
+
+	case "device":
+		for _, dev := range priv.Value {
+			res, id := acl.DeviceIsAllowed(dev)
+			diag.Trace("%s: plugin using device %s is %s by %s\n",
+				username, dev, access.Resolution(res), id)
+			if !res {
+				return false, "using device " + dev + " is not allowed"
+			}
+		}
+
+	case "allow-all-devices":
+		// Access to all devices is as good as privileged mode
+		res, id := acl.CreatePrivilegedIsAllowed()
+		diag.Trace("%s: plugin access to all devices is %s by %s\n",
+			username, access.Resolution(res), id)
+		if !res {
+			return false, "access to all devices is not allowed"
+		}
+
+	case "capabilities":
+		for _, cap := range priv.Value {
+			res, id := acl.CapIsAllowed(cap)
+			diag.Trace("%s: plugin capability %s is %s by %s\n",
+				username, cap, access.Resolution(res), id)
+			if !res {
+				return false, "capability " + cap + " is not allowed"
+			}
+		}
+
+	default:
+		diag.Error("unknown plugin privilege: %s %v\n", priv.Name, priv.Value)
+		return false, "plugin privilege " + priv.Name + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+// Used for both PluginPull and PluginUpgrade requests: both take the
+// plugin reference in the remote query parameter and the list of
+// privileges to grant in the body.
+func PluginPullAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	query, err := RequestQuery(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	var privileges []pluginPrivilege
+	if len(req.RequestBody) > 0 {
+		if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(&privileges); err != nil {
+			return authorization.Response{Err: err.Error()}
+		}
+	}
+	remote := query.Get("remote")
+	diag.Debug("Install plugin request: %s, privileges %#v\n", remote, privileges)
+
+	if ok, msg := checkPlugin(acl, remote, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+	for _, priv := range privileges {
+		if ok, msg := checkPluginPrivilege(acl, priv, req.User); !ok {
+			return authorization.Response{Msg: msg}
+		}
+	}
+	return authorization.Response{Allow: true}
+}
+
+// Authorize PluginCreate request.  The request body is a tar archive
+// with the plugin configuration, which docker doesn't pass to the
+// authorization plugins, so only the plugin name can be checked.
+func PluginCreateAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	query, err := RequestQuery(req)
+	if err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	name := query.Get("name")
+	diag.Debug("Create plugin request: %s\n", name)
+	if ok, msg := checkPlugin(acl, name, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+	// The plugin archive is not passed to authorization plugins, so
+	// the privileges it requests can't be checked.  Treat it as a
+	// privileged container.
+	res, id := acl.CreatePrivilegedIsAllowed()
+	diag.Trace("%s: creating plugin %s is %s by %s\n",
+		req.User, name, access.Resolution(res), id)
+	if !res {
+		return authorization.Response{Msg: "creating plugins is not allowed"}
+	}
+	return authorization.Response{Allow: true}
+}
+
+// Authorize PluginSet request.  The body lists the settings as
+// NAME[.FIELD]=VALUE.  The source of a mount and the path of a device
+// are checked as the corresponding plugin privileges.  As the plugin
+// configuration is not known, a setting without a field may set any
+// of these, so its value is checked as both if it is an absolute
+// path.
+func PluginSetAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	var args []string
+	if len(req.RequestBody) > 0 {
+		if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(&args); err != nil {
+			return authorization.Response{Err: err.Error()}
+		}
+	}
+	diag.Debug("Set plugin request: %v\n", args)
+
+	var mounts, devices pluginPrivilege
+	mounts.Name = "mount"
+	devices.Name = "device"
+	for _, arg := range args {
+		name, value, _ := strings.Cut(arg, "=")
+		field := ""
+		if i := strings.LastIndex(name, "."); i > 0 {
+			field = name[i+1:]
+		}
+		switch field {
+		case "source":
+			mounts.Value = append(mounts.Value, value)
+		case "path":
+			devices.Value = append(devices.Value, value)
+		case "":
+			if strings.HasPrefix(value, "/") {
+				mounts.Value = append(mounts.Value, value)
+				devices.Value = append(devices.Value, value)
+			}
+		}
+	}
+	for _, priv := range []pluginPrivilege{mounts, devices} {
+		if ok, msg := checkPluginPrivilege(acl, priv, req.User); !ok {
+			return authorization.Response{Msg: msg}
+		}
+	}
+	return authorization.Response{Allow: true}
+}
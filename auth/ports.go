@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Check publishing of the host port range spec (N or N-M) on the host
+// address ip.  Empty spec means a port dynamically allocated by docker,
+// which is always allowed.
+func checkHostPort(acl access.ACL, ip, spec, username string) (bool, string) {
+	res, id := acl.HostIpIsAllowed(ip)
+	diag.Trace("%s: publishing ports on address %q is %s by %s\n",
+		username, ip, access.Resolution(res), id)
+	if !res {
+		if ip == "" {
+			return false, "publishing ports on all interfaces is not allowed"
+		}
+		return false, "publishing ports on address " + ip + " is not allowed"
+	}
+
+	if spec == "" {
+		return true, "Ok"
+	}
+	lo, hi, err := access.ParsePortRange(spec)
+	if err != nil {
+		return false, "bad host port " + spec
+	}
+
+	if lo < 1024 {
+		res, id := acl.PrivilegedPortIsAllowed()
+		diag.Trace("%s: publishing privileged port %d is %s by %s\n",
+			username, lo, access.Resolution(res), id)
+		if !res {
+			return false, fmt.Sprintf("publishing privileged port %d is not allowed", lo)
+		}
+	}
+
+	res, id = acl.HostPortIsAllowed(lo, hi)
+	diag.Trace("%s: publishing host port %s is %s by %s\n",
+		username, spec, access.Resolution(res), id)
+	if !res {
+		return false, "publishing host port " + spec + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+func checkPortBindings(acl access.ACL, hc *container.HostConfig, username string) (bool, string) {
+	if hc.PublishAllPorts {
+		// Exposed ports are published on random ports of all
+		// interfaces.
+		if ok, msg := checkHostPort(acl, "", "", username); !ok {
+			return false, msg
+		}
+	}
+	for _, bindings := range hc.PortBindings {
+		for _, b := range bindings {
+			if ok, msg := checkHostPort(acl, b.HostIP, b.HostPort, username); !ok {
+				return false, msg
+			}
+		}
+	}
+	return true, "Ok"
+}
+
+// Check ports published by a swarm service.  Swarm publishes ports on
+// all interfaces.
+func checkEndpointPorts(acl access.ACL, ep *swarm.EndpointSpec, username string) (bool, string) {
+	if ep == nil {
+		return true, "Ok"
+	}
+	for _, p := range ep.Ports {
+		spec := ""
+		if p.PublishedPort != 0 {
+			spec = fmt.Sprintf("%d", p.PublishedPort)
+		}
+		if ok, msg := checkHostPort(acl, "", spec, username); !ok {
+			return false, msg
+		}
+	}
+	return true, "Ok"
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"github.com/docker/docker/api/types/swarm"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Function checking the name of a swarm secret or config.
+type swarmObjectCheck func(acl access.ACL, name, username string) (bool, string)
+
+func checkSecret(acl access.ACL, name, username string) (bool, string) {
+	res, id := acl.SecretIsAllowed(name)
+	diag.Trace("%s: using secret %s is %s by %s\n",
+		username, name, access.Resolution(res), id)
+	if !res {
+		return false, "secret " + name + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+func checkConfig(acl access.ACL, name, username string) (bool, string) {
+	res, id := acl.ConfigIsAllowed(name)
+	diag.Trace("%s: using config %s is %s by %s\n",
+		username, name, access.Resolution(res), id)
+	if !res {
+		return false, "config " + name + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+// Return the name of the secret or config with the given ID, or empty
+// string if not known.  Set by the server when ownership tracking is
+// enabled.
+var (
+	SecretName func(id string) string
+	ConfigName func(id string) string
+)
+
+func secretName(id string) string {
+	if SecretName == nil {
+		return ""
+	}
+	return SecretName(id)
+}
+
+func configName(id string) string {
+	if ConfigName == nil {
+		return ""
+	}
+	return ConfigName(id)
+}
+
+// Check secrets and configs referred to by the container spec of a
+// service.  Docker resolves the references by ID and ignores the
+// names, so the names are looked up by ID.  Objects with unknown IDs
+// are allowed only if no policy applies.
+func checkServiceReferences(acl access.ACL, cs *swarm.ContainerSpec, username string) (bool, string) {
+	if cs == nil {
+		return true, "Ok"
+	}
+	for _, ref := range cs.Secrets {
+		name := ref.SecretName
+		if ref.SecretID != "" {
+			if name = secretName(ref.SecretID); name == "" {
+				res, id := acl.UnknownSecretIsAllowed()
+				diag.Trace("%s: using unknown secret %s is %s by %s\n",
+					username, ref.SecretID, access.Resolution(res), id)
+				if !res {
+					return false, "secret " + ref.SecretID + " is not allowed"
+				}
+				continue
+			}
+		}
+		if ok, msg := checkSecret(acl, name, username); !ok {
+			return false, msg
+		}
+	}
+	for _, ref := range cs.Configs {
+		name := ref.ConfigName
+		if ref.ConfigID != "" {
+			if name = configName(ref.ConfigID); name == "" {
+				res, id := acl.UnknownConfigIsAllowed()
+				diag.Trace("%s: using unknown config %s is %s by %s\n",
+					username, ref.ConfigID, access.Resolution(res), id)
+				if !res {
+					return false, "config " + ref.ConfigID + " is not allowed"
+				}
+				continue
+			}
+		}
+		if ok, msg := checkConfig(acl, name, username); !ok {
+			return false, msg
+		}
+	}
+	return true, "Ok"
+}
+
+// The object is matched as referred to in the path, i.e. by name or
+// ID.  Full IDs of known objects are resolved to names, but ID
+// prefixes are not, so only allow-lists are safe.
+var swarmObjectRe = regexp.MustCompile(`^(?:/v\d+\.\d+)?/(?:secrets|configs)/([^/]+)`)
+
+// Fields of the secret or config spec subject to checking.
+type swarmObjectSpec struct {
+	Name string
+}
+
+// Return authorization function that checks the secret or config name
+// (or ID, resolved by lookup) given in the request URI and/or the name
+// from the spec in the request body.
+func swarmObjectAuth(check swarmObjectCheck, lookup func(string) string, fromURI, fromBody bool) func(access.ACL, authorization.Request) authorization.Response {
+	return func(acl access.ACL, req authorization.Request) authorization.Response {
+		var names []string
+		if fromURI {
+			path, err := RequestPath(req)
+			if err != nil {
+				return authorization.Response{Err: err.Error()}
+			}
+			res := swarmObjectRe.FindStringSubmatch(path)
+			if res == nil {
+				return authorization.Response{Err: errors.New("can't get name from " + path).Error()}
+			}
+			name := res[1]
+			if n := lookup(name); n != "" {
+				name = n
+			}
+			names = append(names, name)
+		}
+		if fromBody {
+			body := &swarmObjectSpec{}
+			if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(body); err != nil {
+				return authorization.Response{Err: err.Error()}
+			}
+			names = append(names, body.Name)
+		}
+		for _, name := range names {
+			if ok, msg := check(acl, name, req.User); !ok {
+				return authorization.Response{Msg: msg}
+			}
+		}
+		return authorization.Response{Allow: true}
+	}
+}
+
+var (
+	SecretCreateAuth = swarmObjectAuth(checkSecret, secretName, false, true)
+	SecretUpdateAuth = swarmObjectAuth(checkSecret, secretName, true, true)
+	SecretAuth = swarmObjectAuth(checkSecret, secretName, true, false)
+	ConfigCreateAuth = swarmObjectAuth(checkConfig, configName, false, true)
+	ConfigUpdateAuth = swarmObjectAuth(checkConfig, configName, true, true)
+	ConfigAuth = swarmObjectAuth(checkConfig, configName, true, false)
+)
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"github.com/docker/docker/api/types/swarm"
+	"sargon/access"
+	"sargon/diag"
+)
+
+// Return hex SHA-256 digest of the seccomp profile.  The profile is
+// compacted first, the way docker CLI does before sending it.
+func seccompDigest(profile string) string {
+	var buf bytes.Buffer
+	data := []byte(profile)
+	if err := json.Compact(&buf, data); err == nil {
+		data = buf.Bytes()
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Check security options.  The no-new-privileges option is always
+// allowed, as it can only reduce privileges.  Custom seccomp profiles
+// are checked by their digest.  If required is true, fail if
+// no-new-privileges is not given, but the ACL requires it.
+func checkSecurityOpt(acl access.ACL, opts []string, required bool, username string) (bool, string) {
+	nnp := false
+	for _, opt := range opts {
+		opt = access.NormalizeSecurityOpt(opt)
+		kv := strings.SplitN(opt, "=", 2)
+		switch kv[0] {
+		case "no-new-privileges":
+			nnp = true
+			if len(kv) == 2 {
+				if b, err := strconv.ParseBool(kv[1]); err == nil {
+					nnp = b
+				}
+			}
+			continue
+
+		case "seccomp":
+			if len(kv) == 2 && kv[1] != "unconfined" && kv[1] != "builtin" {
+				digest := seccompDigest(kv[1])
+				res, id := acl.SeccompProfileIsAllowed(digest)
+				diag.Trace("%s: seccomp profile sha256:%s is %s by %s\n",
+					username, digest, access.Resolution(res), id)
+				if !res {
+					return false, "seccomp profile sha256:" + digest + " is not allowed"
+				}
+				continue
+			}
+		}
+
+		res, id := acl.SecurityOptIsAllowed(opt)
+		diag.Trace("%s: security option %s is %s by %s\n",
+			username, opt, access.Resolution(res), id)
+		if !res {
+			return false, "security option " + opt + " is not allowed"
+		}
+	}
+
+	if !required || nnp {
+		return true, "Ok"
+	}
+	if req, id := acl.NoNewPrivilegesRequired(); req {
+		diag.Trace("%s: no-new-privileges is required by %s\n",
+			username, id)
+		return false, "no-new-privileges security option is required (use --security-opt no-new-privileges)"
+	}
+	return true, "Ok"
+}
+
+// Convert swarm container privileges to the equivalent list of
+// security options.
+func privilegesSecurityOpt(p *swarm.Privileges) (opts []string) {
+	if p == nil {
+		return
+	}
+	if p.NoNewPrivileges {
+		opts = append(opts, "no-new-privileges=true")
+	}
+	if p.Seccomp != nil {
+		switch p.Seccomp.Mode {
+		case swarm.SeccompModeUnconfined:
+			opts = append(opts, "seccomp=unconfined")
+		case swarm.SeccompModeCustom:
+			opts = append(opts, "seccomp=" + string(p.Seccomp.Profile))
+		}
+	}
+	if p.AppArmor != nil && p.AppArmor.Mode == swarm.AppArmorModeDisabled {
+		opts = append(opts, "apparmor=unconfined")
+	}
+	if ctx := p.SELinuxContext; ctx != nil {
+		if ctx.Disable {
+			opts = append(opts, "label=disable")
+		}
+		for _, l := range []struct {
+			kw, val string
+		}{
+			{ "user", ctx.User },
+			{ "role", ctx.Role },
+			{ "type", ctx.Type },
+			{ "level", ctx.Level },
+		} {
+			if l.val != "" {
+				opts = append(opts, "label=" + l.kw + ":" + l.val)
+			}
+		}
+	}
+	return
+}
@@ -3,83 +3,148 @@ package auth
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"github.com/docker/go-plugins-helpers/authorization"
-	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/swarm"
 	"sargon/access"
 	"sargon/diag"
 )
 
-func ServiceCreateAuth(acl access.ACL, req authorization.Request) authorization.Response {
-	var body swarm.ServiceSpec
-	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(&body); err != nil {
-		return authorization.Response{Err: err.Error()}
+// Convert the service spec to the equivalent container create request,
+// so that it can be checked by AllowCreate.  Labels of the service
+// itself are used as container labels.
+func serviceCreateRequest(spec *swarm.ServiceSpec) *createRequest {
+	cs := spec.TaskTemplate.ContainerSpec
+	if cs == nil {
+		cs = &swarm.ContainerSpec{}
 	}
-	contspec := body.TaskTemplate.ContainerSpec
-	diag.Debug("Create service request: %#v", contspec)
-
-	// Check capabilities
-	for _, cap := range contspec.CapabilityAdd {
-		res, id := acl.CapIsAllowed(cap)
-		diag.Trace("%s: adding capability %s is %s by %s\n",
-		      req.User,	cap, access.Resolution(res), id)
-		if !res {
-			diag.Trace("DENY ServiceCreate: capability %s is not allowed\n", cap)
-			return authorization.Response{Msg: "capability " + cap + " is not allowed"}
+	hc := &container.HostConfig{
+		CapAdd: cs.CapabilityAdd,
+		SecurityOpt: privilegesSecurityOpt(cs.Privileges),
+		Mounts: cs.Mounts,
+	}
+	hc.Ulimits = cs.Ulimits
+	hc.Sysctls = cs.Sysctls
+	nc := &network.NetworkingConfig{
+		EndpointsConfig: make(map[string]*network.EndpointSettings),
+	}
+	hc.NetworkMode = network.NetworkNone
+	for _, net := range append(spec.TaskTemplate.Networks, spec.Networks...) {
+		if net.Target == network.NetworkHost {
+			hc.NetworkMode = network.NetworkHost
+		} else {
+			// Swarm accepts network ids as well
+			nc.EndpointsConfig[networkName(net.Target)] = nil
 		}
 	}
-
-	for _, mnt := range contspec.Mounts {
-		diag.Debug("Mount: %#v", mnt)
-		switch mnt.Type {
-		case mount.TypeBind:
-			return checkMountBind(acl, req, mnt)
-		case mount.TypeVolume:
-			return checkMountVolume(acl, req, mnt)
-		default:
-			diag.Error("Ignoring mount request of unsupported type: %#v",
-				mnt)
+	// Swarm restarts tasks on any exit by default
+	hc.RestartPolicy.Name = container.RestartPolicyAlways
+	if rp := spec.TaskTemplate.RestartPolicy; rp != nil {
+		switch rp.Condition {
+		case swarm.RestartPolicyConditionNone:
+			hc.RestartPolicy.Name = container.RestartPolicyDisabled
+		case swarm.RestartPolicyConditionOnFailure:
+			hc.RestartPolicy.Name = container.RestartPolicyOnFailure
 		}
 	}
-	return authorization.Response{Allow: true}
+	if res := spec.TaskTemplate.Resources; res != nil {
+		if l := res.Limits; l != nil {
+			hc.Memory = l.MemoryBytes
+			hc.NanoCPUs = l.NanoCPUs
+			if l.Pids > 0 {
+				pids := l.Pids
+				hc.PidsLimit = &pids
+			}
+		}
+		if r := res.Reservations; r != nil {
+			hc.MemoryReservation = r.MemoryBytes
+		}
+	}
+	return &createRequest{
+		Config: &container.Config{
+			Image: cs.Image,
+			User: cs.User,
+			Labels: spec.Labels,
+		},
+		HostConfig: hc,
+		NetworkingConfig: nc,
+		service: true,
+	}
 }
 
-func checkMountVolume(acl access.ACL, req authorization.Request, mnt mount.Mount) authorization.Response {
-	if mnt.VolumeOptions != nil {
-		diag.Debug("VolumeOptions: %#v", *mnt.VolumeOptions)
-		if mnt.VolumeOptions.DriverConfig != nil {
-			diag.Debug("DriverConfig: %#v", mnt.VolumeOptions.DriverConfig)
-			if mnt.VolumeOptions.DriverConfig.Name == `local` {
-				// silently ignore
-			} else if mpt, err := GetDriverMountPoint(mnt.VolumeOptions.DriverConfig.Name, DriverOpts(mnt.VolumeOptions.DriverConfig.Options)); err == nil {
-				res, id := acl.MountIsAllowed(mpt, mnt.ReadOnly)
-				diag.Trace("%s: binding to %s is %s by %s\n",
-					req.User, mpt, access.Resolution(res), id)
-				if !res {
-					return authorization.Response{Msg: "mounting " + mpt + " is not allowed"}
-				}
-			} else if errors.Is(err, ErrUnknownMountDriver) {
-				diag.Error("unknown volume driver: %s, volume %s, options %#v\n",
-					mnt.VolumeOptions.DriverConfig.Name,
-					mnt.Source,
-					mnt.VolumeOptions.DriverConfig.Options)
-			} else {
-				diag.Error("can't get mountpoint from request %#v: %s\n",
-					mnt, err.Error())
-				return authorization.Response{Err: err.Error()}
+// Check the service spec.  Used for both ServiceCreate and
+// ServiceUpdate, since the latter replaces the whole spec.
+func AllowService(acl access.ACL, spec *swarm.ServiceSpec, username string) (bool, string) {
+	// Swarm pulls the image on each node, so check the registry as
+	// for ImageCreate.
+	if cs := spec.TaskTemplate.ContainerSpec; cs != nil && cs.Image != "" {
+		res, id := acl.RegistryIsAllowed(cs.Image)
+		diag.Trace("%s: pulling from registry of %s is %s by %s\n",
+			username, cs.Image, access.Resolution(res), id)
+		if !res {
+			return false, "pulling from registry " +
+				access.ParseImageRef(cs.Image).Registry + " is not allowed"
+		}
+	}
+	// Plugin services install the plugin on each node, so check it
+	// as for PluginPull.
+	if ps := spec.TaskTemplate.PluginSpec; ps != nil {
+		remote := ps.Remote
+		if remote == "" {
+			remote = ps.Name
+		}
+		if ok, msg := checkPlugin(acl, remote, username); !ok {
+			return false, msg
+		}
+		for _, priv := range ps.Privileges {
+			if priv == nil {
+				continue
+			}
+			if ok, msg := checkPluginPrivilege(acl, pluginPrivilege{priv.Name, priv.Value}, username); !ok {
+				return false, msg
 			}
 		}
 	}
+	if ok, msg := AllowCreate(acl, serviceCreateRequest(spec), username); !ok {
+		return false, msg
+	}
+	// Labels of the task containers
+	if cs := spec.TaskTemplate.ContainerSpec; cs != nil {
+		if ok, msg := checkTaskLabels(acl, cs.Labels, username); !ok {
+			return false, msg
+		}
+	}
+	if ok, msg := checkServiceReferences(acl, spec.TaskTemplate.ContainerSpec, username); !ok {
+		return false, msg
+	}
+	return checkEndpointPorts(acl, spec.EndpointSpec, username)
+}
+
+func ServiceCreateAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	var body swarm.ServiceSpec
+	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(&body); err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Create service request: %#v", body.TaskTemplate.ContainerSpec)
+
+	if ok, msg := AllowService(acl, &body, req.User); !ok {
+		diag.Trace("DENY ServiceCreate: %s\n", msg)
+		return authorization.Response{Msg: msg}
+	}
 	return authorization.Response{Allow: true}
 }
 
-func checkMountBind(acl access.ACL, req authorization.Request, mnt mount.Mount) authorization.Response {
-	res, id := acl.MountIsAllowed(mnt.Source, mnt.ReadOnly)
-	diag.Trace("%s: binding to %s is %s by %s\n",
-		req.User, mnt.Source, access.Resolution(res), id)
-	if !res {
-		return authorization.Response{Msg: "mounting " + mnt.Source + " is not allowed"}
+func ServiceUpdateAuth(acl access.ACL, req authorization.Request) authorization.Response {
+	var body swarm.ServiceSpec
+	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(&body); err != nil {
+		return authorization.Response{Err: err.Error()}
+	}
+	diag.Debug("Update service request: %#v", body.TaskTemplate.ContainerSpec)
+
+	if ok, msg := AllowService(acl, &body, req.User); !ok {
+		diag.Trace("DENY ServiceUpdate: %s\n", msg)
+		return authorization.Response{Msg: msg}
 	}
 	return authorization.Response{Allow: true}
-}
\ No newline at end of file
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"net/url"
+	"github.com/docker/go-plugins-helpers/authorization"
+)
+
+// Return query parameters from the request URI.
+func RequestQuery(req authorization.Request) (url.Values, error) {
+	u, err := url.ParseRequestURI(req.RequestURI)
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(u.RawQuery)
+}
+
+// Return the unescaped path part of the request URI.
+func RequestPath(req authorization.Request) (string, error) {
+	u, err := url.ParseRequestURI(req.RequestURI)
+	if err != nil {
+		return "", err
+	}
+	return u.Path, nil
+}
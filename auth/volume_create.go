@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"errors"
 	"encoding/json"
+	"path/filepath"
+	"strings"
 	"github.com/docker/go-plugins-helpers/authorization"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/volume"
 	"sargon/access"
 	"sargon/diag"
@@ -14,6 +17,7 @@ type DriverOpts map[string]string
 type DriverMountPoint func (opts DriverOpts) (string, error)
 
 var knownDrivers = map[string]DriverMountPoint{
+	"local": LocalMpt,
 	"local-persist": LocalPersistMpt,
 }
 
@@ -25,15 +29,211 @@ func LocalPersistMpt(opts DriverOpts) (mpt string, err error) {
 	return
 }
 
+// Return mountpoint extractor that takes the host path from the driver
+// option with the given name.  Empty name means that the driver doesn't
+// mount anything from the host.
+func OptionMpt(name string) DriverMountPoint {
+	if name == "" {
+		return func (opts DriverOpts) (string, error) {
+			return "", nil
+		}
+	}
+	return func (opts DriverOpts) (mpt string, err error) {
+		mpt, prs := opts[name]
+		if !prs {
+			err = errors.New("No " + name + " specified?")
+		}
+		return
+	}
+}
+
+// Register volume driver whose option opt holds the host path.
+func RegisterVolumeDriver(name, opt string) error {
+	name = NormalizeVolumeDriver(name)
+	if name == "local" {
+		return errors.New("can't redefine built-in driver local")
+	}
+	knownDrivers[name] = OptionMpt(opt)
+	return nil
+}
+
+// Return volume driver name without the default tag.  Empty name
+// stands for the local driver.
+func NormalizeVolumeDriver(name string) string {
+	if name == "" {
+		return "local"
+	}
+	return strings.TrimSuffix(name, ":latest")
+}
+
+// Return value of the mount option name from the comma-separated list
+// of options o.
+func mountOption(o, name string) (string, bool) {
+	for _, opt := range strings.Split(o, ",") {
+		a := strings.SplitN(opt, "=", 2)
+		if a[0] == name {
+			if len(a) == 1 {
+				return "", true
+			}
+			return a[1], true
+		}
+	}
+	return "", false
+}
+
+// File system types of block devices, which can be mounted by the
+// local driver.
+var localFsTypes = map[string]bool{
+	"": true,
+	"none": true,
+	"ext2": true,
+	"ext3": true,
+	"ext4": true,
+	"xfs": true,
+	"btrfs": true,
+	"vfat": true,
+}
+
+// Return the host path or remote file system mounted by the local
+// driver.  Remote file systems are returned as TYPE://SERVER/PATH.
+// Empty string is returned for volumes that don't mount anything
+// from outside of the docker storage.  File system types that can
+// refer to arbitrary host paths in their options (e.g. overlay) are
+// not supported.
+func LocalMpt(opts DriverOpts) (string, error) {
+	device := opts["device"]
+	_, bind := mountOption(opts["o"], "bind")
+	_, rbind := mountOption(opts["o"], "rbind")
+	if bind || rbind {
+		// The kernel ignores file system type for bind mounts
+		if !filepath.IsAbs(device) {
+			return "", errors.New("device " + device + " is not an absolute path")
+		}
+		return device, nil
+	}
+
+	switch fstype := opts["type"]; fstype {
+	case "tmpfs":
+		return "", nil
+
+	case "nfs", "nfs4":
+		path := device
+		host := ""
+		if i := strings.Index(device, ":"); i != -1 {
+			host = device[0:i]
+			path = device[i+1:]
+		}
+		if addr, ok := mountOption(opts["o"], "addr"); ok {
+			host = addr
+		}
+		if host == "" {
+			return "", errors.New("NFS server address not specified")
+		}
+		return "nfs://" + host + "/" + strings.TrimLeft(path, "/"), nil
+
+	case "cifs", "smb3":
+		share := strings.TrimLeft(device, "/")
+		if addr, ok := mountOption(opts["o"], "addr"); ok {
+			if i := strings.Index(share, "/"); i != -1 {
+				share = addr + share[i:]
+			} else {
+				share = addr
+			}
+		}
+		if share == "" {
+			return "", errors.New("CIFS share not specified")
+		}
+		return "cifs://" + share, nil
+
+	default:
+		if !localFsTypes[fstype] {
+			return "", errors.New("file system type " + fstype + " is not supported")
+		}
+		if device == "" {
+			return "", nil
+		}
+		// Block device
+		if !filepath.IsAbs(device) {
+			return "", errors.New("device " + device + " is not an absolute path")
+		}
+		return device, nil
+	}
+}
+
+// Check if the mount point is a remote file system.
+func remoteMountPoint(mpt string) bool {
+	return strings.Contains(mpt, "://")
+}
+
 var ErrUnknownMountDriver = errors.New("Unknown mount driver")
 
 func GetDriverMountPoint(name string, opts DriverOpts) (string, error) {
-	if getmpt, ok := knownDrivers[name]; ok {
+	if getmpt, ok := knownDrivers[NormalizeVolumeDriver(name)]; ok {
 		return getmpt(opts)
 	}
 	return ``, ErrUnknownMountDriver
 }
 
+// Check if the volume driver is allowed by the ACL.
+func checkVolumeDriverName(acl access.ACL, driver, username string) (bool, string) {
+	driver = NormalizeVolumeDriver(driver)
+	res, id := acl.VolumeDriverIsAllowed(driver)
+	diag.Trace("%s: using volume driver %s is %s by %s\n",
+		username, driver, access.Resolution(res), id)
+	if !res {
+		return false, "volume driver " + driver + " is not allowed"
+	}
+	return true, "Ok"
+}
+
+// Check if creating a volume with the given driver and options is
+// allowed.  If the error is returned, the request can't be processed.
+func checkVolumeDriver(acl access.ACL, driver string, opts map[string]string, ro bool, username string) (bool, string, error) {
+	driver = NormalizeVolumeDriver(driver)
+	if ok, msg := checkVolumeDriverName(acl, driver, username); !ok {
+		return false, msg, nil
+	}
+
+	mpt, err := GetDriverMountPoint(driver, DriverOpts(opts))
+	if err == nil {
+		if mpt == "" {
+			return true, "Ok", nil
+		}
+		var res bool
+		var id string
+		if remoteMountPoint(mpt) {
+			res, id = acl.RemoteMountIsAllowed(mpt, ro)
+		} else {
+			res, id = acl.MountIsAllowed(mpt, ro)
+		}
+		diag.Trace("%s: binding to %s is %s by %s\n",
+			username, mpt, access.Resolution(res), id)
+		if !res {
+			return false, "mounting " + mpt + " is not allowed", nil
+		}
+	} else if errors.Is(err, ErrUnknownMountDriver) {
+		diag.Error("unknown volume driver: %s, options %v\n",
+			   driver, opts)
+		return false, "volume driver " + driver + " is not supported", nil
+	} else {
+		diag.Error("can't get mountpoint from driver %s options %#v: %s\n",
+			   driver, opts, err.Error())
+		return false, "", err
+	}
+	return true, "Ok", nil
+}
+
+// Check driver options of a volume mount, which are used if the volume
+// doesn't exist yet.
+func checkMountVolumeOptions(acl access.ACL, mnt mount.Mount, username string) (bool, string, error) {
+	if mnt.VolumeOptions == nil || mnt.VolumeOptions.DriverConfig == nil {
+		return true, "Ok", nil
+	}
+	diag.Debug("DriverConfig: %#v", mnt.VolumeOptions.DriverConfig)
+	return checkVolumeDriver(acl, mnt.VolumeOptions.DriverConfig.Name,
+		mnt.VolumeOptions.DriverConfig.Options, mnt.ReadOnly, username)
+}
+
 func VolumeCreateAuth(acl access.ACL, req authorization.Request) authorization.Response {
 	body := &volume.CreateOptions{}
 	if err := json.NewDecoder(bytes.NewReader(req.RequestBody)).Decode(body); err != nil {
@@ -42,27 +242,18 @@ func VolumeCreateAuth(acl access.ACL, req authorization.Request) authorization.R
 	diag.Debug("Create volume request: volume %s, driver %s, labels %#v, options %#v",
 	      body.Name, body.Driver, body.Labels, body.DriverOpts)
 
-	if body.Driver == "local" {
-		// silently pass
-	} else if mpt, err := GetDriverMountPoint(body.Driver, DriverOpts(body.DriverOpts)); err == nil {
-		res, id := acl.MountIsAllowed(mpt, false)
-		diag.Trace("%s: binding to %s is %s by %s\n",
-		      req.User, mpt, access.Resolution(res), id)
-		if !res {
-			return authorization.Response{Msg: "mounting " +
-							   mpt +
-							   " is not allowed"}
-		}
-	} else if errors.Is(err, ErrUnknownMountDriver) {
-		diag.Error("unknown volume driver: %s, volume %s, labels %v, options %v\n",
-			   body.Driver,
-			   body.Name,
-			   body.Labels,
-			   body.DriverOpts)
-	} else {
-		diag.Error("can't get mountpoint from request %#v: %s\n",
-			   body, err.Error())
+	if ok, msg := checkResourceName(acl, "volume", body.Name, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+
+	if ok, msg := checkLabels(acl, body.Labels, req.User); !ok {
+		return authorization.Response{Msg: msg}
+	}
+
+	if ok, msg, err := checkVolumeDriver(acl, body.Driver, body.DriverOpts, false, req.User); err != nil {
 		return authorization.Response{Err: err.Error()}
+	} else if !ok {
+		return authorization.Response{Msg: msg}
 	}
 	return authorization.Response{Allow: true}
 }
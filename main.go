@@ -67,6 +67,7 @@ func main() {
 		PidFile: "/var/run/sargon.pid",
 		LdapConf: "/etc/ldap.conf:/etc/ldap/ldap.conf:/etc/openldap/ldap.conf",
 		AnonymousUser: "ANONYMOUS",
+		OwnerFile: "/var/lib/sargon/owners.json",
 	}
 	sargon.ReadConfig(config_file)
 
@@ -85,7 +86,7 @@ func main() {
 
 		d, err := ctx.Reborn()
 		if err != nil {
-			diag.Error("can't go daemon: ", err)
+			diag.Error("can't go daemon: %s\n", err)
 		}
 		if d != nil {
 			return
@@ -94,6 +95,12 @@ func main() {
 	}
 	diag.Trace("start up")
 
+	if err := sargon.LoadOwners(); err != nil {
+		diag.Error("can't load owner registry: %s\n", err.Error())
+		os.Exit(1)
+	}
+	sargon.RegisterVolumeDrivers()
+
 	signal_chan := make(chan os.Signal, 1)
 	signal.Notify(signal_chan,
 		      syscall.SIGINT,
@@ -0,0 +1,164 @@
+package owner
+
+import (
+	"os"
+	"errors"
+	"strings"
+	"sync"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sargon/diag"
+)
+
+// Kinds of tracked resources
+const (
+	Container = "container"
+	Volume = "volume"
+	Network = "network"
+	Secret = "secret"
+	Config = "config"
+)
+
+// Ownership record
+type Record struct {
+	Id string
+	Name string
+	User string
+}
+
+// Registry of resource owners, kept in a JSON file.
+type Registry struct {
+	file string
+	mutex sync.Mutex
+	res map[string]map[string]*Record
+}
+
+// Load registry from the file.  Non-existing file is not an error: an
+// empty registry is returned.
+func Load(file string) (*Registry, error) {
+	reg := &Registry{
+		file: file,
+		res: make(map[string]map[string]*Record),
+	}
+	raw, err := ioutil.ReadFile(file)
+	if err == nil {
+		if err = json.Unmarshal(raw, &reg.res); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// Write the registry back to its file.  Must be called with mutex held.
+func (reg *Registry) save() {
+	raw, err := json.Marshal(reg.res)
+	if err != nil {
+		diag.Error("can't encode owner registry: %s\n", err.Error())
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(reg.file), 0750); err != nil {
+		diag.Error("can't create directory for %s: %s\n",
+			reg.file, err.Error())
+		return
+	}
+	tmp := reg.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0640); err != nil {
+		diag.Error("can't write %s: %s\n", tmp, err.Error())
+		return
+	}
+	if err := os.Rename(tmp, reg.file); err != nil {
+		diag.Error("can't rename %s to %s: %s\n",
+			tmp, reg.file, err.Error())
+	}
+}
+
+// Look up the resource by its full id or name.  If prefix is true,
+// unique id prefix is accepted as well.  Must be called with mutex
+// held.
+func (reg *Registry) lookup(kind, ref string, prefix bool) *Record {
+	ref = strings.TrimPrefix(ref, "/")
+	if ref == "" {
+		return nil
+	}
+	for id, r := range reg.res[kind] {
+		if id == ref || r.Name == ref {
+			return r
+		}
+	}
+	if !prefix {
+		return nil
+	}
+	var found *Record
+	for id, r := range reg.res[kind] {
+		if strings.HasPrefix(id, ref) {
+			if found != nil {
+				// Ambiguous prefix
+				return nil
+			}
+			found = r
+		}
+	}
+	return found
+}
+
+// Record that resource of the given kind was created by user.  Any
+// previous record with the same name is removed.
+func (reg *Registry) Add(kind, id, name, user string) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	name = strings.TrimPrefix(name, "/")
+	diag.Debug("%s %s (%s) is owned by %s\n", kind, id, name, user)
+	m, ok := reg.res[kind]
+	if !ok {
+		m = make(map[string]*Record)
+		reg.res[kind] = m
+	}
+	if name != "" {
+		for rid, r := range m {
+			if r.Name == name {
+				delete(m, rid)
+			}
+		}
+	}
+	m[id] = &Record{Id: id, Name: name, User: user}
+	reg.save()
+}
+
+// Remove the resource identified by its name, full id or unique id
+// prefix.
+func (reg *Registry) Remove(kind, ref string) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	if r := reg.lookup(kind, ref, true); r != nil {
+		diag.Debug("forgetting %s %s (%s)\n", kind, r.Id, r.Name)
+		delete(reg.res[kind], r.Id)
+		reg.save()
+	}
+}
+
+// Change the name of the resource identified by ref.
+func (reg *Registry) Rename(kind, ref, name string) {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	if r := reg.lookup(kind, ref, true); r != nil {
+		r.Name = strings.TrimPrefix(name, "/")
+		reg.save()
+	}
+}
+
+// Find the resource by its full id or name.  Id prefixes are not
+// accepted: docker resolves names before id prefixes, so a prefix of
+// a known id can refer to another resource, unknown to the registry.
+// Return a copy of its record or nil if not found.
+func (reg *Registry) Find(kind, ref string) *Record {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+	if r := reg.lookup(kind, ref, false); r != nil {
+		rec := *r
+		return &rec
+	}
+	return nil
+}
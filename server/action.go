@@ -5,346 +5,575 @@ import (
 	"github.com/docker/go-plugins-helpers/authorization"
 	"sargon/access"
 	"sargon/auth"
+	"sargon/owner"
 )
 
 type ActionAuth func (acl access.ACL, req authorization.Request) authorization.Response
 
+// Action categories.  Each category can be referred to in action
+// lists as its name prefixed with "@", e.g. "@readonly".
+type Category uint
+
+const (
+	CatReadonly Category = 1 << iota  // Actions that don't modify anything
+	CatLifecycle                      // Container, volume and network lifecycle
+	CatExec                           // Running commands in containers
+	CatBuild                          // Building, pulling and pushing images
+	CatSwarmAdmin                     // Swarm administration
+	CatAdmin                          // Managing plugins
+	CatDestructive                    // Removing data
+)
+
+var categoryNames = []struct {
+	cat Category
+	name string
+}{
+	{ CatReadonly, "readonly" },
+	{ CatLifecycle, "lifecycle" },
+	{ CatExec, "exec" },
+	{ CatBuild, "build" },
+	{ CatSwarmAdmin, "swarm-admin" },
+	{ CatAdmin, "admin" },
+	{ CatDestructive, "destructive" },
+}
+
+// Return names of the categories in c.
+func (c Category) Names() (names []string) {
+	for _, cn := range categoryNames {
+		if c & cn.cat != 0 {
+			names = append(names, cn.name)
+		}
+	}
+	return
+}
+
+// Return names of all categories.
+func categoryList() (names []string) {
+	for _, cn := range categoryNames {
+		names = append(names, cn.name)
+	}
+	return
+}
+
 type endpoint struct {
 	path *regexp.Regexp
 	method string
 	action string
 	auth ActionAuth
+	resource string  // Kind of resource identified by the URI, if any
+	category Category
 }
 
 // Table of endpoints, generated from
 //   https://docs.docker.com/engine/api/v1.39/swagger.yaml
 var endpoints = []endpoint{
-	{ path: regexp.MustCompile(`^/_ping`),
-	  method: "GET",
-	  action: "SystemPing" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/auth`),
-	  method: "POST",
-	  action: "SystemAuth" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/build`),
-	  method: "POST",
-	  action: "ImageBuild" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/build/prune`),
-	  method: "POST",
-	  action: "BuildPrune" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/commit`),
-	  method: "POST",
-	  action: "ImageCommit" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/configs`),
-	  method: "GET",
-	  action: "ConfigList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/configs/create`),
-	  method: "POST",
-	  action: "ConfigCreate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/configs/.+?`),
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/_ping$`),
+	  method: "GET",
+	  action: "SystemPing",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/auth$`),
+	  method: "POST",
+	  action: "SystemAuth",
+	  category: CatBuild },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/build$`),
+	  method: "POST",
+	  action: "ImageBuild",
+	  category: CatBuild,
+	  auth: auth.ImageBuildAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/build/prune$`),
+	  method: "POST",
+	  action: "BuildPrune",
+	  category: CatBuild | CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/commit$`),
+	  method: "POST",
+	  action: "ImageCommit",
+	  category: CatBuild,
+	  auth: auth.ImageCommitAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/configs$`),
+	  method: "GET",
+	  action: "ConfigList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/configs/create$`),
+	  method: "POST",
+	  action: "ConfigCreate",
+	  category: CatSwarmAdmin,
+	  auth: auth.ConfigCreateAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/configs/.+?$`),
 	  method: "DELETE",
-	  action: "ConfigDelete" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/configs/.+?`),
+	  action: "ConfigDelete",
+	  category: CatSwarmAdmin | CatDestructive,
+	  auth: auth.ConfigAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/configs/.+?$`),
 	  method: "GET",
-	  action: "ConfigInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/configs/.+?/update`),
+	  action: "ConfigInspect",
+	  category: CatReadonly,
+	  auth: auth.ConfigAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/configs/.+?/update$`),
 	  method: "POST",
-	  action: "ConfigUpdate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/create`),
+	  action: "ConfigUpdate",
+	  category: CatSwarmAdmin,
+	  auth: auth.ConfigUpdateAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/create$`),
 	  method: "POST",
 	  action: "ContainerCreate",
+	  category: CatLifecycle,
 	  auth: auth.ContainerCreateAuth },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/json`),
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/json$`),
 	  method: "GET",
-	  action: "ContainerList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/prune`),
+	  action: "ContainerList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/prune$`),
 	  method: "POST",
-	  action: "ContainerPrune" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?`),
+	  action: "ContainerPrune",
+	  category: CatLifecycle | CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?$`),
 	  method: "DELETE",
-	  action: "ContainerDelete" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/archive`),
-	  method: "GET",
-	  action: "ContainerArchive" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/archive`),
+	  action: "ContainerDelete",
+	  category: CatLifecycle | CatDestructive,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/archive$`),
+	  method: "GET",
+	  action: "ContainerArchive",
+	  category: CatReadonly,
+	  auth: auth.ContainerArchiveAuth,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/archive$`),
 	  method: "HEAD",
-	  action: "ContainerArchiveInfo" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/archive`),
+	  action: "ContainerArchiveInfo",
+	  category: CatReadonly,
+	  auth: auth.ContainerArchiveAuth,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/archive$`),
 	  method: "PUT",
-	  action: "PutContainerArchive" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/attach`),
-	  method: "POST",
-	  action: "ContainerAttach" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/attach/ws`),
-	  method: "GET",
-	  action: "ContainerAttachWebsocket" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/changes`),
-	  method: "GET",
-	  action: "ContainerChanges" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/exec`),
-	  method: "POST",
-	  action: "ContainerExec" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/export`),
-	  method: "GET",
-	  action: "ContainerExport" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/json`),
-	  method: "GET",
-	  action: "ContainerInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/kill`),
-	  method: "POST",
-	  action: "ContainerKill" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/logs`),
-	  method: "GET",
-	  action: "ContainerLogs" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/pause`),
-	  method: "POST",
-	  action: "ContainerPause" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/rename`),
-	  method: "POST",
-	  action: "ContainerRename" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/resize`),
-	  method: "POST",
-	  action: "ContainerResize" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/restart`),
-	  method: "POST",
-	  action: "ContainerRestart" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/start`),
-	  method: "POST",
-	  action: "ContainerStart" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/stats`),
-	  method: "GET",
-	  action: "ContainerStats" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/stop`),
-	  method: "POST",
-	  action: "ContainerStop" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/top`),
-	  method: "GET",
-	  action: "ContainerTop" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/unpause`),
-	  method: "POST",
-	  action: "ContainerUnpause" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/update`),
-	  method: "POST",
-	  action: "ContainerUpdate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/containers/.+?/wait`),
-	  method: "POST",
-	  action: "ContainerWait" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/distribution/.+?/json`),
-	  method: "GET",
-	  action: "DistributionInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/events`),
-	  method: "GET",
-	  action: "SystemEvents" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/exec/.+?/json`),
-	  method: "GET",
-	  action: "ExecInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/exec/.+?/resize`),
-	  method: "POST",
-	  action: "ExecResize" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/exec/.+?/start`),
-	  method: "POST",
-	  action: "ExecStart" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/create`),
-	  method: "POST",
-	  action: "ImageCreate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/get`),
-	  method: "GET",
-	  action: "ImageGetAll" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/json`),
-	  method: "GET",
-	  action: "ImageList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/load`),
-	  method: "POST",
-	  action: "ImageLoad" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/prune`),
-	  method: "POST",
-	  action: "ImagePrune" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/search`),
-	  method: "GET",
-	  action: "ImageSearch" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/.+?`),
+	  action: "PutContainerArchive",
+	  category: CatExec,
+	  auth: auth.PutContainerArchiveAuth,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/attach$`),
+	  method: "POST",
+	  action: "ContainerAttach",
+	  category: CatExec,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/attach/ws$`),
+	  method: "GET",
+	  action: "ContainerAttachWebsocket",
+	  category: CatExec,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/changes$`),
+	  method: "GET",
+	  action: "ContainerChanges",
+	  category: CatReadonly,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/exec$`),
+	  method: "POST",
+	  action: "ContainerExec",
+	  category: CatExec,
+	  auth: auth.ContainerExecAuth,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/export$`),
+	  method: "GET",
+	  action: "ContainerExport",
+	  category: CatReadonly,
+	  auth: auth.ContainerExportAuth,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/json$`),
+	  method: "GET",
+	  action: "ContainerInspect",
+	  category: CatReadonly,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/kill$`),
+	  method: "POST",
+	  action: "ContainerKill",
+	  category: CatLifecycle,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/logs$`),
+	  method: "GET",
+	  action: "ContainerLogs",
+	  category: CatReadonly,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/pause$`),
+	  method: "POST",
+	  action: "ContainerPause",
+	  category: CatLifecycle,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/rename$`),
+	  method: "POST",
+	  action: "ContainerRename",
+	  category: CatLifecycle,
+	  auth: auth.ContainerRenameAuth,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/resize$`),
+	  method: "POST",
+	  action: "ContainerResize",
+	  category: CatExec,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/restart$`),
+	  method: "POST",
+	  action: "ContainerRestart",
+	  category: CatLifecycle,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/start$`),
+	  method: "POST",
+	  action: "ContainerStart",
+	  category: CatLifecycle,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/stats$`),
+	  method: "GET",
+	  action: "ContainerStats",
+	  category: CatReadonly,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/stop$`),
+	  method: "POST",
+	  action: "ContainerStop",
+	  category: CatLifecycle,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/top$`),
+	  method: "GET",
+	  action: "ContainerTop",
+	  category: CatReadonly,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/unpause$`),
+	  method: "POST",
+	  action: "ContainerUnpause",
+	  category: CatLifecycle,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/update$`),
+	  method: "POST",
+	  action: "ContainerUpdate",
+	  category: CatLifecycle,
+	  auth: auth.ContainerUpdateAuth,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/containers/.+?/wait$`),
+	  method: "POST",
+	  action: "ContainerWait",
+	  category: CatLifecycle,
+	  resource: owner.Container },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/distribution/.+?/json$`),
+	  method: "GET",
+	  action: "DistributionInspect",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/events$`),
+	  method: "GET",
+	  action: "SystemEvents",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/exec/.+?/json$`),
+	  method: "GET",
+	  action: "ExecInspect",
+	  category: CatReadonly | CatExec },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/exec/.+?/resize$`),
+	  method: "POST",
+	  action: "ExecResize",
+	  category: CatExec },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/exec/.+?/start$`),
+	  method: "POST",
+	  action: "ExecStart",
+	  category: CatExec },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/create$`),
+	  method: "POST",
+	  action: "ImageCreate",
+	  category: CatBuild,
+	  auth: auth.ImageCreateAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/get$`),
+	  method: "GET",
+	  action: "ImageGetAll",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/json$`),
+	  method: "GET",
+	  action: "ImageList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/load$`),
+	  method: "POST",
+	  action: "ImageLoad",
+	  category: CatBuild,
+	  auth: auth.ImageLoadAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/prune$`),
+	  method: "POST",
+	  action: "ImagePrune",
+	  category: CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/search$`),
+	  method: "GET",
+	  action: "ImageSearch",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/.+?$`),
 	  method: "DELETE",
-	  action: "ImageDelete" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/.+?/get`),
-	  method: "GET",
-	  action: "ImageGet" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/.+?/history`),
-	  method: "GET",
-	  action: "ImageHistory" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/.+?/json`),
-	  method: "GET",
-	  action: "ImageInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/.+?/push`),
-	  method: "POST",
-	  action: "ImagePush" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/images/.+?/tag`),
-	  method: "POST",
-	  action: "ImageTag" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/info`),
-	  method: "GET",
-	  action: "SystemInfo" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/networks`),
-	  method: "GET",
-	  action: "NetworkList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/networks/create`),
-	  method: "POST",
-	  action: "NetworkCreate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/networks/prune`),
-	  method: "POST",
-	  action: "NetworkPrune" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/networks/.+?`),
+	  action: "ImageDelete",
+	  category: CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/.+?/get$`),
+	  method: "GET",
+	  action: "ImageGet",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/.+?/history$`),
+	  method: "GET",
+	  action: "ImageHistory",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/.+?/json$`),
+	  method: "GET",
+	  action: "ImageInspect",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/.+?/push$`),
+	  method: "POST",
+	  action: "ImagePush",
+	  category: CatBuild,
+	  auth: auth.ImagePushAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/images/.+?/tag$`),
+	  method: "POST",
+	  action: "ImageTag",
+	  category: CatBuild,
+	  auth: auth.ImageTagAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/info$`),
+	  method: "GET",
+	  action: "SystemInfo",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/networks$`),
+	  method: "GET",
+	  action: "NetworkList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/networks/create$`),
+	  method: "POST",
+	  action: "NetworkCreate",
+	  category: CatLifecycle,
+	  auth: auth.NetworkCreateAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/networks/prune$`),
+	  method: "POST",
+	  action: "NetworkPrune",
+	  category: CatLifecycle | CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/networks/.+?$`),
 	  method: "DELETE",
-	  action: "NetworkDelete" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/networks/.+?`),
-	  method: "GET",
-	  action: "NetworkInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/networks/.+?/connect`),
-	  method: "POST",
-	  action: "NetworkConnect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/networks/.+?/disconnect`),
-	  method: "POST",
-	  action: "NetworkDisconnect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/nodes`),
-	  method: "GET",
-	  action: "NodeList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/nodes/.+?`),
+	  action: "NetworkDelete",
+	  category: CatLifecycle | CatDestructive,
+	  resource: owner.Network },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/networks/.+?$`),
+	  method: "GET",
+	  action: "NetworkInspect",
+	  category: CatReadonly,
+	  resource: owner.Network },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/networks/.+?/connect$`),
+	  method: "POST",
+	  action: "NetworkConnect",
+	  category: CatLifecycle,
+	  auth: auth.NetworkConnectAuth,
+	  resource: owner.Network },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/networks/.+?/disconnect$`),
+	  method: "POST",
+	  action: "NetworkDisconnect",
+	  category: CatLifecycle,
+	  auth: auth.NetworkDisconnectAuth,
+	  resource: owner.Network },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/nodes$`),
+	  method: "GET",
+	  action: "NodeList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/nodes/.+?$`),
 	  method: "DELETE",
-	  action: "NodeDelete" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/nodes/.+?`),
-	  method: "GET",
-	  action: "NodeInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/nodes/.+?/update`),
-	  method: "POST",
-	  action: "NodeUpdate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins`),
-	  method: "GET",
-	  action: "PluginList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/create`),
-	  method: "POST",
-	  action: "PluginCreate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/privileges`),
-	  method: "GET",
-	  action: "GetPluginPrivileges" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/pull`),
-	  method: "POST",
-	  action: "PluginPull" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/.+?`),
+	  action: "NodeDelete",
+	  category: CatSwarmAdmin | CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/nodes/.+?$`),
+	  method: "GET",
+	  action: "NodeInspect",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/nodes/.+?/update$`),
+	  method: "POST",
+	  action: "NodeUpdate",
+	  category: CatSwarmAdmin },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins$`),
+	  method: "GET",
+	  action: "PluginList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/create$`),
+	  method: "POST",
+	  action: "PluginCreate",
+	  category: CatAdmin,
+	  auth: auth.PluginCreateAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/privileges$`),
+	  method: "GET",
+	  action: "GetPluginPrivileges",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/pull$`),
+	  method: "POST",
+	  action: "PluginPull",
+	  category: CatAdmin,
+	  auth: auth.PluginPullAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/.+?$`),
 	  method: "DELETE",
-	  action: "PluginDelete" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/.+?/disable`),
-	  method: "POST",
-	  action: "PluginDisable" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/.+?/enable`),
-	  method: "POST",
-	  action: "PluginEnable" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/.+?/json`),
-	  method: "GET",
-	  action: "PluginInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/.+?/push`),
-	  method: "POST",
-	  action: "PluginPush" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/.+?/set`),
-	  method: "POST",
-	  action: "PluginSet" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/plugins/.+?/upgrade`),
-	  method: "POST",
-	  action: "PluginUpgrade" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/secrets`),
-	  method: "GET",
-	  action: "SecretList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/secrets/create`),
-	  method: "POST",
-	  action: "SecretCreate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/secrets/.+?`),
+	  action: "PluginDelete",
+	  category: CatAdmin | CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/.+?/disable$`),
+	  method: "POST",
+	  action: "PluginDisable",
+	  category: CatAdmin },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/.+?/enable$`),
+	  method: "POST",
+	  action: "PluginEnable",
+	  category: CatAdmin },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/.+?/json$`),
+	  method: "GET",
+	  action: "PluginInspect",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/.+?/push$`),
+	  method: "POST",
+	  action: "PluginPush",
+	  category: CatAdmin },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/.+?/set$`),
+	  method: "POST",
+	  action: "PluginSet",
+	  category: CatAdmin,
+	  auth: auth.PluginSetAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/plugins/.+?/upgrade$`),
+	  method: "POST",
+	  action: "PluginUpgrade",
+	  category: CatAdmin,
+	  auth: auth.PluginPullAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/secrets$`),
+	  method: "GET",
+	  action: "SecretList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/secrets/create$`),
+	  method: "POST",
+	  action: "SecretCreate",
+	  category: CatSwarmAdmin,
+	  auth: auth.SecretCreateAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/secrets/.+?$`),
 	  method: "DELETE",
-	  action: "SecretDelete" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/secrets/.+?`),
+	  action: "SecretDelete",
+	  category: CatSwarmAdmin | CatDestructive,
+	  auth: auth.SecretAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/secrets/.+?$`),
 	  method: "GET",
-	  action: "SecretInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/secrets/.+?/update`),
+	  action: "SecretInspect",
+	  category: CatReadonly,
+	  auth: auth.SecretAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/secrets/.+?/update$`),
 	  method: "POST",
-	  action: "SecretUpdate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/services`),
+	  action: "SecretUpdate",
+	  category: CatSwarmAdmin,
+	  auth: auth.SecretUpdateAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/services$`),
 	  method: "GET",
-	  action: "ServiceList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/services/create`),
+	  action: "ServiceList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/services/create$`),
 	  method: "POST",
 	  action: "ServiceCreate",
+	  category: CatSwarmAdmin,
 	  auth: auth.ServiceCreateAuth },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/services/.+?`),
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/services/.+?$`),
 	  method: "DELETE",
-	  action: "ServiceDelete" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/services/.+?`),
+	  action: "ServiceDelete",
+	  category: CatSwarmAdmin | CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/services/[^/]+$`),
 	  method: "GET",
-	  action: "ServiceInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/services/.+?/logs`),
+	  action: "ServiceInspect",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/services/.+?/logs$`),
 	  method: "GET",
-	  action: "ServiceLogs" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/services/.+?/update`),
+	  action: "ServiceLogs",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/services/.+?/update$`),
 	  method: "POST",
-	  action: "ServiceUpdate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/session`),
+	  action: "ServiceUpdate",
+	  category: CatSwarmAdmin,
+	  auth: auth.ServiceUpdateAuth },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/session$`),
 	  method: "POST",
-	  action: "Session" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/swarm`),
+	  action: "Session",
+	  category: CatBuild },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/swarm$`),
 	  method: "GET",
-	  action: "SwarmInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/swarm/init`),
+	  action: "SwarmInspect",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/swarm/init$`),
 	  method: "POST",
-	  action: "SwarmInit" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/swarm/join`),
+	  action: "SwarmInit",
+	  category: CatSwarmAdmin },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/swarm/join$`),
 	  method: "POST",
-	  action: "SwarmJoin" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/swarm/leave`),
+	  action: "SwarmJoin",
+	  category: CatSwarmAdmin },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/swarm/leave$`),
 	  method: "POST",
-	  action: "SwarmLeave" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/swarm/unlock`),
+	  action: "SwarmLeave",
+	  category: CatSwarmAdmin | CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/swarm/unlock$`),
 	  method: "POST",
-	  action: "SwarmUnlock" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/swarm/unlockkey`),
+	  action: "SwarmUnlock",
+	  category: CatSwarmAdmin },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/swarm/unlockkey$`),
 	  method: "GET",
-	  action: "SwarmUnlockkey" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/swarm/update`),
+	  action: "SwarmUnlockkey",
+	  category: CatSwarmAdmin },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/swarm/update$`),
 	  method: "POST",
-	  action: "SwarmUpdate" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/system/df`),
+	  action: "SwarmUpdate",
+	  category: CatSwarmAdmin },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/system/df$`),
 	  method: "GET",
-	  action: "SystemDataUsage" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/tasks`),
+	  action: "SystemDataUsage",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/tasks$`),
 	  method: "GET",
-	  action: "TaskList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/tasks/.+?`),
+	  action: "TaskList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/tasks/[^/]+$`),
 	  method: "GET",
-	  action: "TaskInspect" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/tasks/.+?/logs`),
+	  action: "TaskInspect",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/tasks/.+?/logs$`),
 	  method: "GET",
-	  action: "TaskLogs" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/version`),
+	  action: "TaskLogs",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/version$`),
 	  method: "GET",
-	  action: "SystemVersion" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/volumes`),
+	  action: "SystemVersion",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/volumes$`),
 	  method: "GET",
-	  action: "VolumeList" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/volumes/create`),
+	  action: "VolumeList",
+	  category: CatReadonly },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/volumes/create$`),
 	  method: "POST",
 	  action: "VolumeCreate",
+	  category: CatLifecycle,
 	  auth: auth.VolumeCreateAuth },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/volumes/prune`),
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/volumes/prune$`),
 	  method: "POST",
-	  action: "VolumePrune" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/volumes/.+?`),
+	  action: "VolumePrune",
+	  category: CatLifecycle | CatDestructive },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/volumes/.+?$`),
 	  method: "DELETE",
-	  action: "VolumeDelete" },
-	{ path: regexp.MustCompile(`^/v\d+\.\d+/volumes/.+?`),
-	  method: "GET",
-	  action: "VolumeInspect" },
+	  action: "VolumeDelete",
+	  category: CatLifecycle | CatDestructive,
+	  resource: owner.Volume },
+	{ path: regexp.MustCompile(`^(?:/v\d+\.\d+)?/volumes/.+?$`),
+	  method: "GET",
+	  action: "VolumeInspect",
+	  category: CatReadonly,
+	  resource: owner.Volume },
 }
 
-func GetAction(method, path string) (string, ActionAuth)  {
-	for _, ep := range endpoints {
-		if ep.method == method &&
-			ep.path.FindStringSubmatch(path) != nil {
-			return ep.action, ep.auth
+func FindEndpoint(method, path string) *endpoint {
+	for i := range endpoints {
+		if endpoints[i].method == method &&
+			endpoints[i].path.FindStringSubmatch(path) != nil {
+			return &endpoints[i]
 		}
 	}
+	return nil
+}
+
+func GetAction(method, path string) (string, ActionAuth)  {
+	if ep := FindEndpoint(method, path); ep != nil {
+		return ep.action, ep.auth
+	}
 	return "NONE", nil
 }
+
+var resourceIdRe = regexp.MustCompile(`^(?:/v\d+\.\d+)?/\w+/([^/]+)`)
+
+// Return the resource id or name from the request path, e.g. the
+// container name from /v1.39/containers/NAME/exec.
+func ResourceId(path string) string {
+	if res := resourceIdRe.FindStringSubmatch(path); res != nil {
+		return res[1]
+	}
+	return ""
+}
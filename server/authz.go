@@ -1,23 +1,21 @@
 package server
 
 import (
-	"strings"
-	"net/url"
 	"github.com/docker/go-plugins-helpers/authorization"
 	"sargon/diag"
 	"sargon/access"
+	"sargon/auth"
 )	
 
 func (srg *Sargon) AuthZReq(req authorization.Request) authorization.Response {
 
-	uri, err := url.QueryUnescape(req.RequestURI)
+	// Get the unescaped path.  Query parameters are removed before
+	// unescaping, so that an escaped question mark can't truncate it.
+	uri, err := auth.RequestPath(req)
 	if err != nil {
 		return authorization.Response{Err: err.Error()}
 	}
 
-	// Remove query parameters
-	uri = (strings.SplitN(uri,"?",2))[0];
-
 	if req.User == "" {
 		req.User = srg.AnonymousUser
 	}
@@ -25,7 +23,13 @@ func (srg *Sargon) AuthZReq(req authorization.Request) authorization.Response {
 	diag.Debug("checking %s request to %s from user %s\n",
               req.RequestMethod, uri, req.User)
 
-	action, auth := GetAction(req.RequestMethod, uri)
+	action := "NONE"
+	var categories []string
+	ep := FindEndpoint(req.RequestMethod, uri)
+	if ep != nil {
+		action = ep.action
+		categories = ep.category.Names()
+	}
 	acl, err := srg.FindUser(req.User)
 	if err != nil {
 		return authorization.Response{Msg: "Autorization denied",
@@ -33,20 +37,36 @@ func (srg *Sargon) AuthZReq(req authorization.Request) authorization.Response {
 	}
 
 	diag.Debug("checking if action %s is allowed\n", action)
-	ok, id := acl.ActionIsAllowed(action)
+	ok, id := acl.ActionIsAllowed(action, categories)
 	diag.Trace("%s: action %s is %s by %s\n",
 	      req.User, action, access.Resolution(ok), id)
 	if !ok {
 		return authorization.Response{Msg: "Action not allowed"}
 	}
 
-	if (auth != nil) {
-		return auth(acl, req)
+	if ep == nil {
+		return authorization.Response{Allow: true}
+	}
+
+	if ep.resource != "" {
+		if ok, msg := srg.checkOwner(acl, req, ep, uri); !ok {
+			return authorization.Response{Msg: msg}
+		}
+	}
+
+	if (ep.auth != nil) {
+		return ep.auth(acl, req)
 	}
 
 	return authorization.Response{Allow: true}
 }
 
 func (srg *Sargon) AuthZRes(req authorization.Request) authorization.Response {
+	if req.User == "" {
+		req.User = srg.AnonymousUser
+	}
+	if srg.owners != nil && req.ResponseStatusCode < 300 {
+		srg.trackOwner(req)
+	}
 	return authorization.Response{Allow: true}
 }
@@ -114,6 +114,28 @@ func FilterGroupCond(username string) string {
 	return group_cond
 }
 
+// Convert the value of a size attribute.
+func ldapSize(entry *ldap.Entry, attr *ldap.EntryAttribute) *int64 {
+	n, err := access.ConvSize(attr.Values[0])
+	if err != nil {
+		diag.Error("%s: bad value for %s: %s\n",
+			entry.DN, attr.Name, err.Error())
+		return nil
+	}
+	return &n
+}
+
+// Convert the value of a CPU limit.
+func ldapCpus(entry *ldap.Entry, attr *ldap.EntryAttribute) *int64 {
+	n, err := access.ConvCpus(attr.Values[0])
+	if err != nil {
+		diag.Error("%s: bad value for %s: %s\n",
+			entry.DN, attr.Name, err.Error())
+		return nil
+	}
+	return &n
+}
+
 func LdapEntryToACE(entry *ldap.Entry) access.ACE {
 	var ace access.ACE
 	ace.Id = entry.DN
@@ -127,6 +149,10 @@ func LdapEntryToACE(entry *ldap.Entry) access.ACE {
 			ace.Allow = attr.Values
 		case `sargonDeny`:
 			ace.Deny = attr.Values
+		case `sargonRole`:
+			ace.Role = attr.Values
+		case `sargonOwnerOnly`:
+			ace.OwnerOnly = attr.Values
 		case `sargonOrder`:
 			n, err := strconv.Atoi(attr.Values[0])
 			if err == nil {
@@ -137,18 +163,136 @@ func LdapEntryToACE(entry *ldap.Entry) access.ACE {
 		case `sargonAllowPrivileged`:
 			ace.AllowPrivileged = new(bool)
 			*ace.AllowPrivileged = attr.Values[0] == "TRUE"
+		case `sargonAllowHostNetwork`:
+			ace.AllowHostNetwork = new(bool)
+			*ace.AllowHostNetwork = attr.Values[0] == "TRUE"
+		case `sargonAllowHostPid`:
+			ace.AllowHostPid = new(bool)
+			*ace.AllowHostPid = attr.Values[0] == "TRUE"
+		case `sargonAllowHostIpc`:
+			ace.AllowHostIpc = new(bool)
+			*ace.AllowHostIpc = attr.Values[0] == "TRUE"
+		case `sargonAllowHostUts`:
+			ace.AllowHostUts = new(bool)
+			*ace.AllowHostUts = attr.Values[0] == "TRUE"
+		case `sargonAllowHostUserns`:
+			ace.AllowHostUserns = new(bool)
+			*ace.AllowHostUserns = attr.Values[0] == "TRUE"
+		case `sargonAllowHostCgroupns`:
+			ace.AllowHostCgroupns = new(bool)
+			*ace.AllowHostCgroupns = attr.Values[0] == "TRUE"
+		case `sargonAllowPrivilegedExec`:
+			ace.AllowPrivilegedExec = new(bool)
+			*ace.AllowPrivilegedExec = attr.Values[0] == "TRUE"
+		case `sargonExecUser`:
+			ace.ExecUser = attr.Values
+		case `sargonCopyFrom`:
+			ace.CopyFrom = attr.Values
+		case `sargonCopyTo`:
+			ace.CopyTo = attr.Values
+		case `sargonContainerUser`:
+			ace.ContainerUser = attr.Values
+		case `sargonNetwork`:
+			ace.Network = attr.Values
+		case `sargonNetworkDriver`:
+			ace.NetworkDriver = attr.Values
+		case `sargonNetworkParent`:
+			ace.NetworkParent = attr.Values
+		case `sargonNetworkSubnet`:
+			ace.NetworkSubnet = attr.Values
+		case `sargonAllowInternalNetwork`:
+			ace.AllowInternalNetwork = new(bool)
+			*ace.AllowInternalNetwork = attr.Values[0] == "TRUE"
+		case `sargonAllowAttachableNetwork`:
+			ace.AllowAttachableNetwork = new(bool)
+			*ace.AllowAttachableNetwork = attr.Values[0] == "TRUE"
+		case `sargonAllowIngressNetwork`:
+			ace.AllowIngressNetwork = new(bool)
+			*ace.AllowIngressNetwork = attr.Values[0] == "TRUE"
+		case `sargonSecret`:
+			ace.Secret = attr.Values
+		case `sargonConfig`:
+			ace.Config = attr.Values
+		case `sargonPlugin`:
+			ace.Plugin = attr.Values
+		case `sargonExtraHost`:
+			ace.ExtraHost = attr.Values
+		case `sargonSysctl`:
+			ace.Sysctl = attr.Values
+		case `sargonBuildRemote`:
+			ace.BuildRemote = attr.Values
+		case `sargonBuildPlatform`:
+			ace.BuildPlatform = attr.Values
+		case `sargonRestartPolicy`:
+			ace.RestartPolicy = attr.Values
+		case `sargonVolumeDriver`:
+			ace.VolumeDriver = attr.Values
+		case `sargonResourceName`:
+			ace.ResourceName = attr.Values
+		case `sargonAllowAnonymousName`:
+			ace.AllowAnonymousName = new(bool)
+			*ace.AllowAnonymousName = attr.Values[0] == "TRUE"
+		case `sargonRequireLabel`:
+			ace.RequireLabel = attr.Values
+		case `sargonForbidLabel`:
+			ace.ForbidLabel = attr.Values
+		case `sargonExecEnv`:
+			ace.ExecEnv = attr.Values
+		case `sargonExecCommand`:
+			ace.ExecCommand = attr.Values
 		case `sargonMaxMemory`:
-			n, err := access.ConvSize(attr.Values[0])
-			if err == nil {
-				ace.MaxMemory = &n
-			}
+			ace.MaxMemory = ldapSize(entry, attr)
 		case `sargonMaxKernelMemory`:
-			n, err := access.ConvSize(attr.Values[0])
-			if err == nil {
-				ace.MaxKernelMemory = &n
-			}
+			ace.MaxKernelMemory = ldapSize(entry, attr)
+		case `sargonMaxMemorySwap`:
+			ace.MaxMemorySwap = ldapSize(entry, attr)
+		case `sargonMaxMemoryReservation`:
+			ace.MaxMemoryReservation = ldapSize(entry, attr)
+		case `sargonMaxShmSize`:
+			ace.MaxShmSize = ldapSize(entry, attr)
+		case `sargonMaxNanoCpus`:
+			ace.MaxNanoCpus = ldapCpus(entry, attr)
+		case `sargonMaxCpuShares`:
+			ace.MaxCpuShares = ldapSize(entry, attr)
+		case `sargonMaxPidsLimit`:
+			ace.MaxPidsLimit = ldapSize(entry, attr)
+		case `sargonCpusetCpus`:
+			ace.CpusetCpus = attr.Values
+		case `sargonCpusetMems`:
+			ace.CpusetMems = attr.Values
+		case `sargonMaxUlimit`:
+			ace.MaxUlimit = attr.Values
+		case `sargonRequireLimits`:
+			ace.RequireLimits = new(bool)
+			*ace.RequireLimits = attr.Values[0] == "TRUE"
 		case `sargonAllowCapability`:
 			ace.AllowCapability = attr.Values
+		case `sargonDevice`:
+			ace.Device = attr.Values
+		case `sargonDeviceCgroupRule`:
+			ace.DeviceCgroupRule = attr.Values
+		case `sargonDeviceRequest`:
+			ace.DeviceRequest = attr.Values
+		case `sargonSecurityOpt`:
+			ace.SecurityOpt = attr.Values
+		case `sargonSeccompProfile`:
+			ace.SeccompProfile = attr.Values
+		case `sargonRequireNoNewPrivileges`:
+			ace.RequireNoNewPrivileges = new(bool)
+			*ace.RequireNoNewPrivileges = attr.Values[0] == "TRUE"
+		case `sargonHostPort`:
+			ace.HostPort = attr.Values
+		case `sargonHostIp`:
+			ace.HostIp = attr.Values
+		case `sargonAllowPrivilegedPorts`:
+			ace.AllowPrivilegedPorts = new(bool)
+			*ace.AllowPrivilegedPorts = attr.Values[0] == "TRUE"
+		case `sargonImage`:
+			ace.Image = attr.Values
+		case `sargonRegistry`:
+			ace.Registry = attr.Values
+		case `sargonRepository`:
+			ace.Repository = attr.Values
 		}
 	}
 	return ace
@@ -182,32 +326,57 @@ func MatchHost(hostname, username string) (result bool) {
 	return 
 }
 
-func ExpandUser(ace *access.ACE, usr *user.User) {
-	re := regexp.MustCompile(`\$(((\w+)\b)|\{\w+\})`)
-	for i, mp := range ace.Mount {
-		ace.Mount[i] = re.ReplaceAllStringFunc(mp,
+var userVarRe = regexp.MustCompile(`\$(((\w+)\b)|\{\w+\})`)
+
+// Expand user variables in each string from the list.  Return the
+// new list.  A pattern with a variable that can't be expanded (e.g.
+// $uid of a user that has no system account) is replaced with an
+// empty one, which matches nothing.
+func expandUserVars(list []string, usr *user.User) []string {
+	if len(list) == 0 {
+		return list
+	}
+	res := make([]string, len(list))
+	for i, s := range list {
+		ok := true
+		value := func (v string) string {
+			if v == "" {
+				ok = false
+			}
+			return v
+		}
+		res[i] = userVarRe.ReplaceAllStringFunc(s,
 			func (kw string) string {
 				switch kw {
 				case `$uid`,`${uid}`:
-					return usr.Uid
+					return value(usr.Uid)
 				case `$gid`,`${gid}`:
-					return usr.Gid
+					return value(usr.Gid)
 				case `$name`,`${name}`:
-					return usr.Username
+					return value(usr.Username)
 				case `$home`,`${home}`,`$dir`,`${dir}`:
-					return usr.HomeDir
+					return value(usr.HomeDir)
 				}
-				return `$` + kw
+				return kw
 			})
-		if mp != ace.Mount[i] {
-			diag.Debug("expand %s => %s\n", mp, ace.Mount[i]);
+		if !ok {
+			diag.Debug("can't expand %s for %s\n", s, usr.Username)
+			if strings.HasPrefix(s, "!") {
+				res[i] = "!"
+			} else {
+				res[i] = ""
+			}
+		} else if s != res[i] {
+			diag.Debug("expand %s => %s\n", s, res[i]);
 		}
 	}
+	return res
 }
 
-func FilterLdapEntriesToACL(entries []*ldap.Entry, username string) access.ACL {
-	acl := access.NewSargonACL(len(entries))
-	i := 0
+// Return the system user record for username.  If there is none, return
+// a record with the user name alone, so that at least $name can be
+// expanded.
+func lookupUser(username string) *user.User {
 	usr, err := user.Lookup(username)
 	if err != nil {
 		if _, ok := err.(user.UnknownUserError); ok {
@@ -215,7 +384,31 @@ func FilterLdapEntriesToACL(entries []*ldap.Entry, username string) access.ACL {
 		} else {
 			diag.Error("can't get user record for %s\n", username);
 		}
+		return &user.User{Username: username}
 	}
+	return usr
+}
+
+func ExpandUser(ace *access.ACE, usr *user.User) {
+	ace.Mount = expandUserVars(ace.Mount, usr)
+	ace.Repository = expandUserVars(ace.Repository, usr)
+	ace.HostPort = expandUserVars(ace.HostPort, usr)
+	ace.ExecUser = expandUserVars(ace.ExecUser, usr)
+	ace.ContainerUser = expandUserVars(ace.ContainerUser, usr)
+	ace.CopyFrom = expandUserVars(ace.CopyFrom, usr)
+	ace.CopyTo = expandUserVars(ace.CopyTo, usr)
+	ace.ResourceName = expandUserVars(ace.ResourceName, usr)
+	ace.RequireLabel = expandUserVars(ace.RequireLabel, usr)
+	ace.BuildRemote = expandUserVars(ace.BuildRemote, usr)
+	ace.Network = expandUserVars(ace.Network, usr)
+	ace.Secret = expandUserVars(ace.Secret, usr)
+	ace.Config = expandUserVars(ace.Config, usr)
+}
+
+func FilterLdapEntriesToACL(entries []*ldap.Entry, username string) access.ACL {
+	acl := access.NewSargonACL(len(entries))
+	i := 0
+	usr := lookupUser(username)
 	for _, ent := range entries {
 		t := LdapEntryToACE(ent)
 		var match bool
@@ -230,9 +423,7 @@ func FilterLdapEntriesToACL(entries []*ldap.Entry, username string) access.ACL {
 			}
 		}
 		if match {
-			if err == nil {
-				ExpandUser(&t, usr)
-			}
+			ExpandUser(&t, usr)
 			acl[i] = t
 			i += 1
 		}
@@ -333,22 +524,94 @@ func NewTlsConfig(cf LdapConfig) (tlsconf *tls.Config, ok bool) {
 	return
 }
 
-func (srg *Sargon) FindUserLdap (username string) (access.ACL, error) {
+// Attributes of sargonACL and sargonRoleDef objects.
+var ldapAttributes = []string{
+	"dn",
+	"sargonUser",
+	"sargonHost",
+	"sargonAllow",
+	"sargonDeny",
+	"sargonRole",
+	"sargonOwnerOnly",
+	"sargonOrder",
+	"sargonMount",
+	"sargonAllowPrivileged",
+	"sargonAllowHostNetwork",
+	"sargonAllowHostPid",
+	"sargonAllowHostIpc",
+	"sargonAllowHostUts",
+	"sargonAllowHostUserns",
+	"sargonAllowHostCgroupns",
+	"sargonAllowPrivilegedExec",
+	"sargonExecUser",
+	"sargonContainerUser",
+	"sargonCopyFrom",
+	"sargonCopyTo",
+	"sargonRestartPolicy",
+	"sargonNetwork",
+	"sargonNetworkDriver",
+	"sargonNetworkParent",
+	"sargonNetworkSubnet",
+	"sargonAllowInternalNetwork",
+	"sargonAllowAttachableNetwork",
+	"sargonAllowIngressNetwork",
+	"sargonPlugin",
+	"sargonSecret",
+	"sargonConfig",
+	"sargonExtraHost",
+	"sargonSysctl",
+	"sargonBuildRemote",
+	"sargonBuildPlatform",
+	"sargonVolumeDriver",
+	"sargonResourceName",
+	"sargonAllowAnonymousName",
+	"sargonRequireLabel",
+	"sargonForbidLabel",
+	"sargonExecEnv",
+	"sargonExecCommand",
+	"sargonMaxMemory",
+	"sargonMaxKernelMemory",
+	"sargonMaxMemorySwap",
+	"sargonMaxMemoryReservation",
+	"sargonMaxShmSize",
+	"sargonMaxNanoCpus",
+	"sargonMaxCpuShares",
+	"sargonMaxPidsLimit",
+	"sargonCpusetCpus",
+	"sargonCpusetMems",
+	"sargonMaxUlimit",
+	"sargonRequireLimits",
+	"sargonAllowCapability",
+	"sargonDevice",
+	"sargonDeviceCgroupRule",
+	"sargonDeviceRequest",
+	"sargonSecurityOpt",
+	"sargonSeccompProfile",
+	"sargonRequireNoNewPrivileges",
+	"sargonHostPort",
+	"sargonHostIp",
+	"sargonAllowPrivilegedPorts",
+	"sargonImage",
+	"sargonRegistry",
+	"sargonRepository",
+}
+
+func (srg *Sargon) FindUserLdap (username string) (access.ACL, map[string]access.ACE, error) {
 	if srg.LdapConf == "" {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	diag.Debug("Looking up user %s in LDAP\n", username)
 	cf := LdapConfig{}
 	err := cf.ReadPath(srg.LdapConf)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	net, addr, ssl := uriToNetAddr(cf[`uri`])
 	if net == "" {
 		diag.Error("can't parse URI\n")
-		return nil, errors.New("invalid LDAP URI")
+		return nil, nil, errors.New("invalid LDAP URI")
 	}
 
 	var l *ldap.Conn
@@ -363,7 +626,7 @@ func (srg *Sargon) FindUserLdap (username string) (access.ACL, error) {
 
 	if err != nil {
 		diag.Error("can't connect to LDAP: %s\n", err.Error())
-		return nil, err
+		return nil, nil, err
 	}
 	defer l.Close()
 
@@ -373,7 +636,7 @@ func (srg *Sargon) FindUserLdap (username string) (access.ACL, error) {
 		err := l.StartTLS(tlsconf)
 		if err != nil {
 			diag.Error("can't start TLS session: %s\n", err.Error())
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
@@ -391,7 +654,7 @@ func (srg *Sargon) FindUserLdap (username string) (access.ACL, error) {
 				diag.Error("can't read password file %s: %s\n",
 					pwfile,
 					err.Error())
-				return nil, err
+				return nil, nil, err
 			}
 		}
 	}
@@ -399,7 +662,7 @@ func (srg *Sargon) FindUserLdap (username string) (access.ACL, error) {
 	err = l.Bind(user, passwd)
 	if err != nil {
 		diag.Error("can't bind as %s: %s\n", srg.LdapUser, err.Error())
-		return nil, err
+		return nil, nil, err
 	}
 
 	group_cond := FilterGroupCond(username)
@@ -450,37 +713,55 @@ func (srg *Sargon) FindUserLdap (username string) (access.ACL, error) {
 		0,
 		false,
 		filter,
-		[]string{
-			"dn",
-			"sargonUser",
-			"sargonHost",
-			"sargonAllow",
-			"sargonDeny",
-			"sargonOrder",
-			"sargonMount",
-			"sargonAllowPrivileged",
-			"sargonMaxMemory",
-			"sargonMaxKernelMemory",
-			"sargonAllowCapability",
-		},
+		ldapAttributes,
 		nil)
 	sr, err := l.Search(req)
 	if err != nil {
 		diag.Error("search request failed: %s\n", err.Error())
-		return nil, err
+		return nil, nil, err
 	}
 
-	return FilterLdapEntriesToACL(sr.Entries, username), nil
+	acl := FilterLdapEntriesToACL(sr.Entries, username)
+
+	diag.Debug("looking up roles\n")
+	req = ldap.NewSearchRequest(
+		cf[`base`],
+		scope,
+		deref,
+		0,
+		0,
+		false,
+		"(objectClass=sargonRoleDef)",
+		append([]string{"cn"}, ldapAttributes...),
+		nil)
+	sr, err = l.Search(req)
+	if err != nil {
+		diag.Error("search request failed: %s\n", err.Error())
+		return nil, nil, err
+	}
+
+	roles := make(map[string]access.ACE)
+	for _, ent := range sr.Entries {
+		name := ent.GetAttributeValue("cn")
+		if name == "" {
+			continue
+		}
+		roles[name] = LdapEntryToACE(ent)
+	}
+
+	return acl, roles, nil
 }
 
 func (srg *Sargon) FindUser (username string) (acl access.ACL, err error) {
-	acl, err = srg.FindUserLdap(username)
+	acl, ldapRoles, err := srg.FindUserLdap(username)
 	diag.Debug("Reading %d default ACLs", len(srg.ACL))
+	usr := lookupUser(username)
 	for i, ent := range srg.ACL {
 		if ent.MatchUser(username) {
 			if ent.Id == "" {
 				ent.Id = `#` + strconv.Itoa(i)
 			}
+			ExpandUser(&ent, usr)
 			acl = append(acl, ent)
 			err = nil
 		} else {
@@ -488,5 +769,22 @@ func (srg *Sargon) FindUser (username string) (acl access.ACL, err error) {
 		}
 	}
 	sort.Stable(acl)
+
+	// Roles defined in LDAP override those from the configuration.
+	roles := make(map[string]access.ACE)
+	for name, role := range srg.Roles {
+		roles[name] = role
+	}
+	for name, role := range ldapRoles {
+		roles[name] = role
+	}
+	for name, role := range roles {
+		if role.Id == "" {
+			role.Id = `@` + name
+		}
+		ExpandUser(&role, usr)
+		roles[name] = role
+	}
+	acl.ExpandRoles(roles, categoryList())
 	return
 }
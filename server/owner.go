@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/docker/go-plugins-helpers/authorization"
+	"sargon/access"
+	"sargon/auth"
+	"sargon/diag"
+	"sargon/owner"
+)
+
+// Check if the user is allowed to perform the action of the endpoint
+// on the resource identified by the request path.
+func (srg *Sargon) checkOwner(acl access.ACL, req authorization.Request, ep *endpoint, path string) (bool, string) {
+	action, kind := ep.action, ep.resource
+	only, id := acl.OwnerOnlyAction(action, ep.category.Names())
+	if !only {
+		return true, "Ok"
+	}
+	if ok, msg := srg.checkOwnerOf(req.User, action, kind, ResourceId(path), id); !ok {
+		return false, msg
+	}
+	switch action {
+	case "NetworkConnect", "NetworkDisconnect":
+		// The action changes the container as well
+		var body struct { Container string }
+		if !decodeBody(req.RequestBody, &body) {
+			return false, "can't decode request body"
+		}
+		return srg.checkOwnerOf(req.User, action, owner.Container, body.Container, id)
+	}
+	return true, "Ok"
+}
+
+// Check if the resource of the given kind is owned by the user.  Id
+// identifies the ACL entry that restricts the action to owners.
+func (srg *Sargon) checkOwnerOf(user, action, kind, ref, id string) (bool, string) {
+	var rec *owner.Record
+	if srg.owners != nil {
+		rec = srg.owners.Find(kind, ref)
+	}
+	res := rec != nil && rec.User == user
+	if rec != nil {
+		diag.Trace("%s: action %s on %s %s owned by %s is %s by %s\n",
+			user, action, kind, ref, rec.User,
+			access.Resolution(res), id)
+	} else {
+		diag.Trace("%s: action %s on %s %s with unknown owner is %s by %s\n",
+			user, action, kind, ref,
+			access.Resolution(res), id)
+	}
+	if !res {
+		return false, "you are not the owner of " + kind + " " + ref
+	}
+	return true, "Ok"
+}
+
+func decodeBody(body []byte, v interface{}) bool {
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(v); err != nil {
+		diag.Error("can't decode body: %s\n", err.Error())
+		return false
+	}
+	return true
+}
+
+// Update the owner registry after a successful request.
+func (srg *Sargon) trackOwner(req authorization.Request) {
+	path, err := auth.RequestPath(req)
+	if err != nil {
+		return
+	}
+	ep := FindEndpoint(req.RequestMethod, path)
+	if ep == nil {
+		return
+	}
+	query, err := auth.RequestQuery(req)
+	if err != nil {
+		return
+	}
+
+	switch ep.action {
+	case "ContainerCreate":
+		var res struct { Id string }
+		if decodeBody(req.ResponseBody, &res) && res.Id != "" {
+			srg.owners.Add(owner.Container, res.Id, query.Get("name"), req.User)
+		}
+
+	case "VolumeCreate":
+		var res struct { Name string }
+		if decodeBody(req.ResponseBody, &res) && res.Name != "" {
+			srg.owners.Add(owner.Volume, res.Name, res.Name, req.User)
+		}
+
+	case "NetworkCreate":
+		var res struct { Id string }
+		var body struct { Name string }
+		if decodeBody(req.ResponseBody, &res) && res.Id != "" &&
+			decodeBody(req.RequestBody, &body) {
+			srg.owners.Add(owner.Network, res.Id, body.Name, req.User)
+		}
+
+	case "SecretCreate", "ConfigCreate":
+		// Recorded to resolve the IDs services refer to them by
+		var res struct { ID string }
+		var body struct { Name string }
+		kind := owner.Secret
+		if ep.action == "ConfigCreate" {
+			kind = owner.Config
+		}
+		if decodeBody(req.ResponseBody, &res) && res.ID != "" &&
+			decodeBody(req.RequestBody, &body) {
+			srg.owners.Add(kind, res.ID, body.Name, req.User)
+		}
+
+	case "ContainerRename":
+		srg.owners.Rename(owner.Container, ResourceId(path), query.Get("name"))
+
+	case "ContainerDelete", "VolumeDelete", "NetworkDelete":
+		srg.owners.Remove(ep.resource, ResourceId(path))
+
+	case "SecretDelete":
+		srg.owners.Remove(owner.Secret, ResourceId(path))
+
+	case "ConfigDelete":
+		srg.owners.Remove(owner.Config, ResourceId(path))
+
+	case "ContainerPrune":
+		var res struct { ContainersDeleted []string }
+		if decodeBody(req.ResponseBody, &res) {
+			for _, id := range res.ContainersDeleted {
+				srg.owners.Remove(owner.Container, id)
+			}
+		}
+
+	case "VolumePrune":
+		var res struct { VolumesDeleted []string }
+		if decodeBody(req.ResponseBody, &res) {
+			for _, id := range res.VolumesDeleted {
+				srg.owners.Remove(owner.Volume, id)
+			}
+		}
+
+	case "NetworkPrune":
+		var res struct { NetworksDeleted []string }
+		if decodeBody(req.ResponseBody, &res) {
+			for _, id := range res.NetworksDeleted {
+				srg.owners.Remove(owner.Network, id)
+			}
+		}
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"io/ioutil"
 	"log"
 	"sargon/access"
+	"sargon/auth"
+	"sargon/diag"
+	"sargon/owner"
 )
 
 type Sargon struct {
@@ -16,7 +19,11 @@ type Sargon struct {
 	LdapPass string
 	LdapTLS bool
 	AnonymousUser string
+	OwnerFile string
+	VolumeDrivers map[string]string
 	ACL access.ACL
+	Roles map[string]access.ACE
+	owners *owner.Registry
 }
 
 func (srg *Sargon) ReadConfig(f string) {
@@ -30,3 +37,50 @@ func (srg *Sargon) ReadConfig(f string) {
 	}
 }
 
+
+// Load the registry of resource owners.  Empty OwnerFile disables
+// ownership tracking.
+func (srg *Sargon) LoadOwners() error {
+	if srg.OwnerFile == "" {
+		return nil
+	}
+	reg, err := owner.Load(srg.OwnerFile)
+	if err != nil {
+		return err
+	}
+	srg.owners = reg
+	auth.ContainerOwner = func(ref string) string {
+		if rec := reg.Find(owner.Container, ref); rec != nil {
+			return rec.User
+		}
+		return ""
+	}
+	auth.NetworkName = func(ref string) string {
+		if rec := reg.Find(owner.Network, ref); rec != nil {
+			return rec.Name
+		}
+		return ""
+	}
+	auth.SecretName = func(id string) string {
+		if rec := reg.Find(owner.Secret, id); rec != nil {
+			return rec.Name
+		}
+		return ""
+	}
+	auth.ConfigName = func(id string) string {
+		if rec := reg.Find(owner.Config, id); rec != nil {
+			return rec.Name
+		}
+		return ""
+	}
+	return nil
+}
+
+// Register configured volume drivers.
+func (srg *Sargon) RegisterVolumeDrivers() {
+	for name, opt := range srg.VolumeDrivers {
+		if err := auth.RegisterVolumeDriver(name, opt); err != nil {
+			diag.Error("volume driver %s: %s\n", name, err.Error())
+		}
+	}
+}